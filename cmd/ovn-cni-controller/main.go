@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/controller"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/k8s"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb"
+)
+
+func main() {
+	ovnNb := flag.String("ovn-nb", "tcp:192.168.12.177:6641", "OVN northbound database endpoint")
+	workers := flag.Int("workers", 2, "number of workers per resource")
+	aclLogRate := flag.Int("acl-log-rate", 20, "ACL log messages per second allowed per ovn-controller")
+	aclLogBurst := flag.Int("acl-log-burst", 100, "burst of ACL log messages allowed per ovn-controller")
+	clusterCIDRs := flag.String("cluster-cidrs", "", "comma separated in-cluster CIDRs not subject to egress firewalls")
+	floatingIPRouter := flag.String("floating-ip-router", "", "tenant logical router holding floating IPs, disabled when empty")
+	serviceSwitches := flag.String("service-switches", "public", "comma separated logical switches that get the service load balancers")
+	serviceRouters := flag.String("service-routers", "", "comma separated logical routers that get the service load balancers")
+	healthCheckSourceIP := flag.String("health-check-source-ip", "", "unused address of the service switches load balancer health checks are sent from, disabled when empty")
+	healthCheckInterval := flag.Int("health-check-interval", 5, "seconds between load balancer health checks")
+	healthCheckTimeout := flag.Int("health-check-timeout", 20, "seconds before a load balancer health check fails")
+	healthCheckSuccessCount := flag.Int("health-check-success-count", 3, "successful checks marking a backend online")
+	healthCheckFailureCount := flag.Int("health-check-failure-count", 3, "failed checks marking a backend offline")
+	gatewayRouter := flag.String("gateway-router", "", "gateway router exposing LoadBalancer services, disabled when empty")
+	publicIPPool := flag.String("public-ip-pool", "", "IPAM public pool LoadBalancer services get their external IP from")
+	logicalSwitch := flag.String("logical-switch", "public", "logical switch holding the pod ports")
+	coppRates := flag.String("copp-rates", "arp=100,arp-resolve=100,dhcpv4-opts=100,dhcpv6-opts=100,icmp4-error=100,icmp6-error=100,nd-na=100,nd-ns=100,nd-ns-resolve=100,reject=100,tcp-reset=100",
+		"comma separated protocol=packets-per-second control plane rate limits, disabled when empty")
+	flag.Parse()
+
+	copp, err := parseRates(*coppRates)
+	if err != nil {
+		log.Fatalf("invalid -copp-rates: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	k8sClient, err := k8s.CreateClient()
+	if err != nil {
+		log.Fatalf("Error creating Kubernetes Client: %v", err)
+	}
+	dynamicClient, err := k8s.CreateDynamicClient()
+	if err != nil {
+		log.Fatalf("Error creating Kubernetes dynamic Client: %v", err)
+	}
+	ovnClient, err := ovnnb.CreateOvnNbClient(*ovnNb)
+	if err != nil {
+		log.Fatalf("error on creating ovn client: %v", err)
+	}
+	defer ovnClient.Close()
+
+	c, err := controller.NewController(k8sClient, dynamicClient, ovnClient, controller.Options{
+		ACLLogRate:          *aclLogRate,
+		ACLLogBurst:         *aclLogBurst,
+		ClusterCIDRs:        splitList(*clusterCIDRs),
+		FloatingIPRouter:    *floatingIPRouter,
+		ServiceSwitches:     splitList(*serviceSwitches),
+		ServiceRouters:      splitList(*serviceRouters),
+		LogicalSwitch:       *logicalSwitch,
+		HealthCheckSourceIP: *healthCheckSourceIP,
+		HealthCheck: v1alpha1.HealthCheck{
+			Interval:     *healthCheckInterval,
+			Timeout:      *healthCheckTimeout,
+			SuccessCount: *healthCheckSuccessCount,
+			FailureCount: *healthCheckFailureCount,
+		},
+		GatewayRouter: *gatewayRouter,
+		PublicIPPool:  *publicIPPool,
+		CoppRates:     copp,
+	})
+	if err != nil {
+		log.Fatalf("error on creating controller: %v", err)
+	}
+	if err := c.Run(ctx, *workers); err != nil {
+		log.Fatalf("controller failed: %v", err)
+	}
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseRates(value string) (map[string]int, error) {
+	rates := map[string]int{}
+	for _, item := range splitList(value) {
+		protocol, rate, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not protocol=rate", item)
+		}
+		if !slices.Contains(ovnnb.CoppProtocols, protocol) {
+			return nil, fmt.Errorf("unknown protocol %q", protocol)
+		}
+		n, err := strconv.Atoi(rate)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid rate %q for %s", rate, protocol)
+		}
+		rates[protocol] = n
+	}
+	return rates, nil
+}
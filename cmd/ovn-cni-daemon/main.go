@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/daemon"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/k8s"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovs"
+)
+
+func main() {
+	ovnNb := flag.String("ovn-nb", "tcp:192.168.12.177:6641", "OVN northbound database endpoint")
+	nodeName := flag.String("node-name", os.Getenv("NODE_NAME"), "name of the node the daemon runs on")
+	logicalSwitch := flag.String("logical-switch", "public", "logical switch holding the pod ports")
+	bridge := flag.String("bridge", "br-int", "integration bridge of the pod interfaces")
+	flowExportConfig := flag.String("flow-export-config", "", "JSON file with the sFlow/IPFIX/NetFlow export and sample collector set configuration of the bridge, disabled when empty")
+	providerNetworks := flag.String("provider-networks", "", "comma separated network:bridge[:uplink] provider networks of the node, e.g. physnet1:br-physnet1:eth1")
+	workers := flag.Int("workers", 2, "number of workers")
+	flag.Parse()
+
+	var networks []daemon.ProviderNetwork
+	for _, item := range strings.Split(*providerNetworks, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			log.Fatalf("invalid provider network %q, expected network:bridge[:uplink]", item)
+		}
+		pn := daemon.ProviderNetwork{Name: parts[0], Bridge: parts[1]}
+		if len(parts) == 3 {
+			pn.Uplink = parts[2]
+		}
+		networks = append(networks, pn)
+	}
+
+	var flowExport *ovs.FlowExport
+	if *flowExportConfig != "" {
+		data, err := os.ReadFile(*flowExportConfig)
+		if err != nil {
+			log.Fatalf("error reading flow export config: %v", err)
+		}
+		flowExport = &ovs.FlowExport{}
+		if err := json.Unmarshal(data, flowExport); err != nil {
+			log.Fatalf("error parsing flow export config: %v", err)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	k8sClient, err := k8s.CreateClient()
+	if err != nil {
+		log.Fatalf("Error creating Kubernetes Client: %v", err)
+	}
+	ovnClient, err := ovnnb.CreateOvnNbClient(*ovnNb)
+	if err != nil {
+		log.Fatalf("error on creating ovn client: %v", err)
+	}
+	defer ovnClient.Close()
+	ovsClient, err := ovs.CreateOVSclient()
+	if err != nil {
+		log.Fatalf("error on creating ovs client: %v", err)
+	}
+	defer ovsClient.Close()
+
+	d, err := daemon.NewDaemon(k8sClient, ovnClient, ovsClient, daemon.Options{
+		NodeName:         *nodeName,
+		LogicalSwitch:    *logicalSwitch,
+		Bridge:           *bridge,
+		FlowExport:       flowExport,
+		ProviderNetworks: networks,
+	})
+	if err != nil {
+		log.Fatalf("error on creating daemon: %v", err)
+	}
+	if err := d.Run(ctx, *workers); err != nil {
+		log.Fatalf("daemon failed: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/net_utils"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovs"
+	"github.com/vishvananda/netlink"
+)
+
+// runCapture implements "ovn-cni capture <namespace>/<vm> [--if net1]": it
+// mirrors the OVS port of the vm interface to a temporary internal port and
+// writes what it sees as pcap until interrupted.
+func runCapture(args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ContinueOnError)
+	ifName := fs.String("if", "eth0", "interface of the vm to capture")
+	output := fs.String("w", "-", "pcap file to write, - for stdout")
+	count := fs.Int("c", 0, "stop after this many packets, 0 for no limit")
+	bridge := fs.String("bridge", "br-int", "OVS bridge of the vm ports")
+	ovnNb := fs.String("ovn-nb", "tcp:192.168.12.177:6641", "OVN northbound database endpoint")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: ovn-cni capture <namespace>/<vm> [flags]\n")
+		fs.PrintDefaults()
+	}
+	// Accept the target before or after the flags.
+	target := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		target, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if target == "" && fs.NArg() > 0 {
+		target = fs.Arg(0)
+	}
+	namespace, vmName, ok := strings.Cut(target, "/")
+	if !ok || namespace == "" || vmName == "" {
+		fs.Usage()
+		return fmt.Errorf("expected <namespace>/<vm>, got %q", target)
+	}
+	// Keep stdout for the pcap stream.
+	log.SetOutput(os.Stderr)
+
+	// 1. Resolve the attachment to its OVS port
+	ovnClient, err := ovnnb.CreateOvnNbClient(*ovnNb)
+	if err != nil {
+		return err
+	}
+	lsp, err := ovnClient.FindPodLogicalPort(namespace, vmName, *ifName)
+	ovnClient.Close()
+	if err != nil {
+		return err
+	}
+	if lsp == nil {
+		return fmt.Errorf("no port found for interface %s of vm %s/%s", *ifName, namespace, vmName)
+	}
+
+	// 2. Create the capture port and mirror the vm port to it
+	ovsClient, err := ovs.CreateOVSclient()
+	if err != nil {
+		return err
+	}
+	defer ovsClient.Close()
+	capturePort := fmt.Sprintf("cap%d", os.Getpid()%1000000)
+	mirrorName := "capture-" + capturePort
+	if err := ovsClient.AddInternalPort(*bridge, capturePort); err != nil {
+		return err
+	}
+	defer func() {
+		if err := ovsClient.DelPort(*bridge, capturePort); err != nil {
+			log.Printf("Error on deleting capture port %s: %v", capturePort, err)
+		}
+	}()
+	if err := ovsClient.AddMirror(*bridge, mirrorName, lsp.Name, capturePort); err != nil {
+		return err
+	}
+	defer func() {
+		if err := ovsClient.DelMirror(*bridge, mirrorName); err != nil {
+			log.Printf("Error on deleting mirror %s: %v", mirrorName, err)
+		}
+	}()
+	if err := setLinkUp(capturePort, 5*time.Second); err != nil {
+		return err
+	}
+
+	// 3. Write pcap until interrupted
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	log.Printf("Capturing %s of %s/%s (port %s), press Ctrl-C to stop", *ifName, namespace, vmName, lsp.Name)
+	n, err := net_utils.CapturePackets(ctx, capturePort, w, *count)
+	log.Printf("%d packets captured", n)
+	return err
+}
+
+// setLinkUp waits for OVS to create the internal port device and brings it up.
+func setLinkUp(name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		link, err := netlink.LinkByName(name)
+		if err == nil {
+			return netlink.LinkSetUp(link)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for interface %s: %v", name, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
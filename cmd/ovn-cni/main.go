@@ -2,22 +2,29 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	types100 "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/cni/pkg/version"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
 	cniTypes "github.com/cybercoder/ik8s-ovn-cni/pkg/cni/types"
 	"github.com/cybercoder/ik8s-ovn-cni/pkg/k8s"
 	"github.com/cybercoder/ik8s-ovn-cni/pkg/net_utils"
 	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb"
 	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovs"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
 )
 
 func cmdAdd(args *skel.CmdArgs) error {
@@ -43,6 +50,14 @@ func cmdAdd(args *skel.CmdArgs) error {
 		log.Printf("error loading args: %v", err)
 		return err
 	}
+	conf := cniTypes.NetConf{}
+	if err := json.Unmarshal(args.StdinData, &conf); err != nil {
+		log.Printf("error loading netconf: %v", err)
+		return err
+	}
+	if conf.Mode != "" && conf.Mode != cniTypes.ModeVeth && conf.Mode != cniTypes.ModeVhostUser {
+		return fmt.Errorf("unknown attachment mode %q", conf.Mode)
+	}
 	// 1. find kubevirt vm name using kube api
 	k8sClient, err := k8s.CreateClient()
 	if err != nil {
@@ -79,28 +94,134 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return err
 	}
 
-	// 3. Create veth pair
+	// 3. Create veth pair, or the vhost-user socket directory of the pod
+	var hostMAC, containerMac *string
+	socketPath := ""
+	if conf.Mode == cniTypes.ModeVhostUser {
+		gid := net_utils.DefaultVhostUserSocketGID
+		if conf.VhostUserSocketGID != nil {
+			gid = *conf.VhostUserSocketGID
+		}
+		dir, err := net_utils.VhostUserSocketDir(conf.VhostUserSocketDir, string(pod.UID), gid)
+		if err != nil {
+			log.Printf("Error preparing vhost-user socket: %v", err)
+			return err
+		}
+		socketPath = filepath.Join(dir, hostIf)
+		// QEMU owns the guest interface, there is no host side MAC.
+		hostMAC = &ipamResponse.MacAddress
+		containerMac = &ipamResponse.MacAddress
+	} else {
+		hostMAC, containerMac, err = net_utils.CreateStableVeth(hostIf, args.IfName, args.Netns, ipamResponse.MacAddress, ipamResponse.Address)
+		if err != nil {
+			log.Printf("Error creating veth pair: %v", err)
+			// return err
+		}
+	}
+
+	// 4. Add port to ovs
+	if conf.Mode == cniTypes.ModeVhostUser {
+		// Without the port QEMU has nothing to connect its socket to.
+		err = oclient.AddVhostUserPort("br-int", hostIf, socketPath)
+		if err != nil {
+			log.Printf("Error adding vhost-user port to ovs: %v", err)
+			return err
+		}
+	} else {
+		err = oclient.AddPort("br-int", hostIf, "system", *hostMAC)
+		if err != nil {
+			log.Printf("Error adding port to ovs: %v", err)
+			// return err
+		}
+	}
 
-	hostMAC, containerMac, err := net_utils.CreateStableVeth(hostIf, args.IfName, args.Netns, ipamResponse.MacAddress, ipamResponse.Address)
+	// 5. Connect the logical switch to its provider network
+	if conf.ProviderNetwork != "" {
+		err = ovnClient.EnsureLocalnetPort("public", conf.ProviderNetwork, conf.VLAN)
+		if err != nil {
+			log.Printf("Error connecting logical switch public to provider network %s: %v", conf.ProviderNetwork, err)
+			// return err
+		}
+	}
+
+	// 6. Add port to ovn logical switch
+	log.Printf("mac address %s", *hostMAC)
+	lspIDs := map[string]string{
+		ovnnb.ExternalIDNamespace: string(k8sArgs.K8S_POD_NAMESPACE),
+		ovnnb.ExternalIDPod:       string(k8sArgs.K8S_POD_NAME),
+		ovnnb.ExternalIDVM:        vmName,
+		ovnnb.ExternalIDIP:        strings.Split(ipamResponse.Address, "/")[0],
+		ovnnb.ExternalIDInterface: args.IfName,
+	}
+	if conf.RuntimeConfig.Bandwidth != nil {
+		// Only ADD sees the capability, so the daemon keeps these limits.
+		if value, err := json.Marshal(conf.RuntimeConfig.Bandwidth); err == nil {
+			lspIDs[ovnnb.ExternalIDBandwidth] = string(value)
+		}
+	}
+	err = ovnClient.CreateLogicalPort("public", hostIf, *containerMac, lspIDs)
 	if err != nil {
-		log.Printf("Error creating veth pair: %v", err)
+		log.Printf("Error creating logical port on logical switch public: %v", err)
 		// return err
 	}
 
-	// 4. Add port to ovs
-	err = oclient.AddPort("br-int", hostIf, "system", *hostMAC)
+	// 7. Pass VLAN tagged frames through to the guest
+	if conf.VLANPassthru {
+		err = ovnClient.SetLogicalPortVLANPassthru(hostIf, true)
+		if err != nil {
+			log.Printf("Error setting vlan-passthru on %s: %v", hostIf, err)
+			// return err
+		}
+		err = ovnClient.ReconcileLogicalSwitchVLANPassthru("public")
+		if err != nil {
+			log.Printf("Error setting vlan-passthru on logical switch public: %v", err)
+			// return err
+		}
+	}
+
+	// 8. Publish DNS records for the vm
+	hostnames := dnsHostnames(vmName, string(k8sArgs.K8S_POD_NAMESPACE), conf.DNSDomain)
+	err = ovnClient.AddDNSRecords("public", hostnames, strings.Split(ipamResponse.Address, "/")[0])
 	if err != nil {
-		log.Printf("Error adding port to ovs: %v", err)
+		log.Printf("Error adding dns records on logical switch public: %v", err)
 		// return err
 	}
 
-	// 5. Add port to ovn logical switch
-	log.Printf("mac address %s", *hostMAC)
-	err = ovnClient.CreateLogicalPort("public", hostIf, *containerMac)
+	// 9. Enforce the bandwidth limits of the pod
+	bw := podBandwidth(conf, pod.Annotations)
+	err = oclient.SetInterfaceIngressPolicing(hostIf, net_utils.Kilo(bw.EgressRate), net_utils.Kilo(bw.EgressBurst))
 	if err != nil {
-		log.Printf("Error creating logical port on logical switch public: %v", err)
+		log.Printf("Error setting egress bandwidth on %s: %v", hostIf, err)
 		// return err
 	}
+	err = ovnClient.SetLogicalPortBandwidth("public", hostIf, net_utils.Kilo(bw.IngressRate), net_utils.Kilo(bw.IngressBurst))
+	if err != nil {
+		log.Printf("Error setting ingress bandwidth on %s: %v", hostIf, err)
+		// return err
+	}
+
+	// 10. Shape the traffic to the pod with a linux-htb queue, which
+	// netdev ports such as vhost-user ones do not support
+	if conf.Mode != cniTypes.ModeVhostUser {
+		queue, err := net_utils.QueueFromAnnotations(pod.Annotations)
+		if err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+		err = oclient.SetPortQueue(hostIf, queue.MinRate, queue.MaxRate, queue.Priority)
+		if err != nil {
+			log.Printf("Error setting queue on %s: %v", hostIf, err)
+			// return err
+		}
+	}
+
+	// 11. Tell KubeVirt where the vhost-user socket of the interface is
+	if socketPath != "" {
+		err = publishVhostUserSocket(k8sClient, pod, args.IfName, socketPath)
+		if err != nil {
+			log.Printf("Error annotating pod with vhost-user socket: %v", err)
+			// return err
+		}
+	}
 
 	// ✅ Build minimal CNI result
 	_, ipNet, err := net.ParseCIDR(ipamResponse.Address + "/32")
@@ -115,10 +236,11 @@ func cmdAdd(args *skel.CmdArgs) error {
 		CNIVersion: version.Current(),
 		Interfaces: []*types100.Interface{
 			{
-				Mtu:     1500,
-				Name:    args.IfName,
-				Mac:     *hostMAC,
-				Sandbox: args.Netns,
+				Mtu:        1500,
+				Name:       args.IfName,
+				Mac:        *hostMAC,
+				Sandbox:    args.Netns,
+				SocketPath: socketPath,
 			},
 		},
 	}
@@ -152,6 +274,11 @@ func cmdDel(args *skel.CmdArgs) error {
 		log.Printf("error loading args: %v", err)
 		return err
 	}
+	conf := cniTypes.NetConf{}
+	if err := json.Unmarshal(args.StdinData, &conf); err != nil {
+		log.Printf("error loading netconf: %v", err)
+		return err
+	}
 	// 1. find kubevirt vm name using kube api
 	k8sClient, err := k8s.CreateClient()
 	if err != nil {
@@ -175,19 +302,98 @@ func cmdDel(args *skel.CmdArgs) error {
 		log.Printf("Error on deleting logical switch port %s: %v", hostIf, err)
 		return err
 	}
+	err = ovnClient.ReconcileLogicalSwitchVLANPassthru("public")
+	if err != nil {
+		log.Printf("Error on reconciling vlan-passthru of logical switch public: %v", err)
+		return err
+	}
 	err = ovsClient.DelPort("br-int", hostIf)
 	if err != nil {
 		log.Printf("Error on deleting port %s from ovs: %v", hostIf, err)
 		return err
 	}
+	if conf.Mode == cniTypes.ModeVhostUser {
+		err = net_utils.RemoveVhostUserSocketDir(conf.VhostUserSocketDir, string(pod.UID))
+		if err != nil {
+			log.Printf("Error on removing vhost-user socket dir: %v", err)
+			return err
+		}
+	}
+	// Stale records only leave a name pointing at a released address, so
+	// they do not fail the teardown of the pod.
+	hostnames := dnsHostnames(vmName, string(k8sArgs.K8S_POD_NAMESPACE), conf.DNSDomain)
+	err = ovnClient.DeleteDNSRecords("public", hostnames)
+	if err != nil {
+		log.Printf("Error on deleting dns records %v: %v", hostnames, err)
+	}
 
 	return nil
 }
 
+// publishVhostUserSocket records the socket path of the interface in the
+// vhostuser-sockets annotation of the pod, for KubeVirt to hand to QEMU.
+func publishVhostUserSocket(client kubernetes.Interface, pod *corev1.Pod, ifName, socketPath string) error {
+	sockets := map[string]string{}
+	if value, ok := pod.Annotations[v1alpha1.VhostUserSocketsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &sockets); err != nil {
+			log.Printf("⚠️ Replacing invalid %s annotation %q", v1alpha1.VhostUserSocketsAnnotation, value)
+			sockets = map[string]string{}
+		}
+	}
+	sockets[ifName] = socketPath
+	value, err := json.Marshal(sockets)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]string{v1alpha1.VhostUserSocketsAnnotation: string(value)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Pods(pod.Namespace).Patch(context.Background(), pod.Name, k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// dnsHostnames returns the names a vm is published under: vmname.namespace and,
+// when a domain is configured, vmname.namespace.domain.
+func dnsHostnames(vmName, namespace, domain string) []string {
+	name := strings.ToLower(fmt.Sprintf("%s.%s", vmName, namespace))
+	hostnames := []string{name}
+	if domain = strings.Trim(domain, "."); domain != "" {
+		hostnames = append(hostnames, name+"."+strings.ToLower(domain))
+	}
+	return hostnames
+}
+
+// podBandwidth returns the limits handed over by the bandwidth capability,
+// falling back to the pod annotations when the runtime does not support it.
+func podBandwidth(conf cniTypes.NetConf, annotations map[string]string) net_utils.Bandwidth {
+	if conf.RuntimeConfig.Bandwidth != nil {
+		return *conf.RuntimeConfig.Bandwidth
+	}
+	bw, err := net_utils.BandwidthFromAnnotations(annotations)
+	if err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+	return bw
+}
+
 func cmdCheck(args *skel.CmdArgs) error {
 	return nil
 }
 
 func main() {
+	// The runtime passes CNI commands through the environment, so
+	// arguments only come from an operator running a subcommand.
+	if len(os.Args) > 1 && os.Args[1] == "capture" {
+		if err := runCapture(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "capture: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	skel.PluginMain(cmdAdd, cmdCheck, cmdDel, version.All, "ovn-cni")
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb"
+)
+
+func main() {
+	ovnNb := flag.String("ovn-nb", "tcp:192.168.12.177:6641", "OVN northbound database endpoint")
+	tenant := flag.String("tenant", "", "tenant name, used to name the switch and router")
+	routerIP := flag.String("router-ip", "", "router address on the tenant switch, e.g. 10.10.0.1/24")
+	externalSwitch := flag.String("external-switch", "public", "logical switch of the external network")
+	externalIP := flag.String("external-ip", "", "router address on the external switch, e.g. 203.0.113.10/24")
+	gateway := flag.String("gateway", "", "nexthop of the default route on the external network")
+	gatewayChassis := flag.String("gateway-chassis", "", "comma separated chassis hosting the gateway, highest priority first")
+	copp := flag.String("copp", "", "control plane protection policy of the tenant switch and router, e.g. ovn-cni as created by the controller, none when empty")
+	del := flag.Bool("delete", false, "delete the tenant topology instead of creating it")
+	flag.Parse()
+
+	if *tenant == "" {
+		log.Fatalf("-tenant is required")
+	}
+
+	ovnClient, err := ovnnb.CreateOvnNbClient(*ovnNb)
+	if err != nil {
+		log.Fatalf("error on creating ovn client: %v", err)
+	}
+	defer ovnClient.Close()
+
+	if *del {
+		if err := ovnClient.DeleteGatewayTopology(*tenant, *externalSwitch); err != nil {
+			log.Fatalf("failed to delete topology of tenant %s: %v", *tenant, err)
+		}
+		return
+	}
+
+	if *routerIP == "" || *externalIP == "" {
+		log.Fatalf("-router-ip and -external-ip are required")
+	}
+	chassis := []string{}
+	for _, name := range strings.Split(*gatewayChassis, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			chassis = append(chassis, name)
+		}
+	}
+	err = ovnClient.EnsureGatewayTopology(ovnnb.GatewayTopology{
+		Tenant:          *tenant,
+		RouterIP:        *routerIP,
+		ExternalSwitch:  *externalSwitch,
+		ExternalIP:      *externalIP,
+		ExternalGateway: *gateway,
+		GatewayChassis:  chassis,
+		Copp:            *copp,
+	})
+	if err != nil {
+		log.Fatalf("failed to build topology of tenant %s: %v", *tenant, err)
+	}
+}
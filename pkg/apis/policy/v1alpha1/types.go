@@ -0,0 +1,107 @@
+// Package v1alpha1 mirrors the subset of the upstream network-policy-api
+// (policy.networking.k8s.io/v1alpha1) read by the ovn-cni controller. The
+// resources are watched through the dynamic client, so only the fields used
+// here need to be declared.
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const GroupName = "policy.networking.k8s.io"
+
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha1"}
+
+var (
+	AdminNetworkPolicyResource         = SchemeGroupVersion.WithResource("adminnetworkpolicies")
+	BaselineAdminNetworkPolicyResource = SchemeGroupVersion.WithResource("baselineadminnetworkpolicies")
+)
+
+// Rule actions. Pass is only valid in AdminNetworkPolicy.
+const (
+	RuleActionAllow = "Allow"
+	RuleActionDeny  = "Deny"
+	RuleActionPass  = "Pass"
+)
+
+type AdminNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AdminNetworkPolicySpec `json:"spec"`
+}
+
+type AdminNetworkPolicySpec struct {
+	// Priority orders the policies, lower values take precedence.
+	Priority int32                           `json:"priority"`
+	Subject  AdminNetworkPolicySubject       `json:"subject"`
+	Ingress  []AdminNetworkPolicyIngressRule `json:"ingress,omitempty"`
+	Egress   []AdminNetworkPolicyEgressRule  `json:"egress,omitempty"`
+}
+
+type BaselineAdminNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec BaselineAdminNetworkPolicySpec `json:"spec"`
+}
+
+type BaselineAdminNetworkPolicySpec struct {
+	Subject AdminNetworkPolicySubject       `json:"subject"`
+	Ingress []AdminNetworkPolicyIngressRule `json:"ingress,omitempty"`
+	Egress  []AdminNetworkPolicyEgressRule  `json:"egress,omitempty"`
+}
+
+// AdminNetworkPolicySubject selects pods either by namespace or by namespace
+// and pod labels. Exactly one field is set.
+type AdminNetworkPolicySubject struct {
+	Namespaces *metav1.LabelSelector `json:"namespaces,omitempty"`
+	Pods       *NamespacedPod        `json:"pods,omitempty"`
+}
+
+type NamespacedPod struct {
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+	PodSelector       metav1.LabelSelector `json:"podSelector"`
+}
+
+type AdminNetworkPolicyIngressRule struct {
+	Name   string                    `json:"name,omitempty"`
+	Action string                    `json:"action"`
+	From   []AdminNetworkPolicyPeer  `json:"from"`
+	Ports  *[]AdminNetworkPolicyPort `json:"ports,omitempty"`
+}
+
+type AdminNetworkPolicyEgressRule struct {
+	Name   string                    `json:"name,omitempty"`
+	Action string                    `json:"action"`
+	To     []AdminNetworkPolicyPeer  `json:"to"`
+	Ports  *[]AdminNetworkPolicyPort `json:"ports,omitempty"`
+}
+
+// AdminNetworkPolicyPeer is a set of pods or, for egress rules, a set of
+// networks or nodes. Exactly one field is set.
+type AdminNetworkPolicyPeer struct {
+	Namespaces *metav1.LabelSelector `json:"namespaces,omitempty"`
+	Pods       *NamespacedPod        `json:"pods,omitempty"`
+	Nodes      *metav1.LabelSelector `json:"nodes,omitempty"`
+	Networks   []string              `json:"networks,omitempty"`
+}
+
+// AdminNetworkPolicyPort selects a destination port. Exactly one field is set.
+type AdminNetworkPolicyPort struct {
+	PortNumber *Port      `json:"portNumber,omitempty"`
+	NamedPort  *string    `json:"namedPort,omitempty"`
+	PortRange  *PortRange `json:"portRange,omitempty"`
+}
+
+type Port struct {
+	Protocol string `json:"protocol"`
+	Port     int32  `json:"port"`
+}
+
+type PortRange struct {
+	Protocol string `json:"protocol,omitempty"`
+	Start    int32  `json:"start"`
+	End      int32  `json:"end"`
+}
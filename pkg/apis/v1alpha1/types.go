@@ -0,0 +1,245 @@
+// Package v1alpha1 holds the custom resources of the ovn.ik8s.ir API group
+// handled by the ovn-cni controller.
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const GroupName = "ovn.ik8s.ir"
+
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha1"}
+
+// FromUnstructured converts an object handed out by a dynamic informer into
+// one of the typed resources of this package.
+func FromUnstructured(obj any, out any) error {
+	u, ok := obj.(runtime.Unstructured)
+	if !ok {
+		return runtime.NewNotRegisteredErrForType("unstructured", nil)
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), out)
+}
+
+// SecurityGroupsAnnotation lists, comma separated, the security groups of the
+// pod namespace attached to the pod interfaces, e.g. "web,ssh".
+const SecurityGroupsAnnotation = GroupName + "/security-groups"
+
+// ACLLoggingAnnotation enables audit logging of the ACLs applying to a
+// namespace (or, on an admin network policy, of that policy). The value is a
+// JSON ACLLogging, e.g. {"deny": "alert", "allow": "info"}.
+const ACLLoggingAnnotation = GroupName + "/acl-logging"
+
+// ACLLogging holds the log severity per verdict, one of "alert", "warning",
+// "notice", "info" or "debug". An empty severity disables logging.
+type ACLLogging struct {
+	Deny  string `json:"deny,omitempty"`
+	Allow string `json:"allow,omitempty"`
+}
+
+// FloatingIPAnnotation maps a public IP to the pod interface through a
+// dnat_and_snat rule on the tenant router. The IP comes from the IPAM public
+// pool: "auto" takes the address IPAM assigns to the pod, an explicit one,
+// e.g. "203.0.113.10", must be that address.
+const FloatingIPAnnotation = GroupName + "/floating-ip"
+
+// FloatingIPDistributedAnnotation set to "true" makes the floating IP
+// distributed: it is answered for on the chassis hosting the pod, with the pod
+// interface MAC, instead of on the gateway chassis.
+const FloatingIPDistributedAnnotation = GroupName + "/floating-ip-distributed"
+
+// HealthCheckAnnotation turns on OVN health checks of the backends of a
+// Service, so that dead backends stop receiving traffic before their
+// EndpointSlice catches up. The value is a JSON HealthCheck; "{}" keeps the
+// controller defaults.
+const HealthCheckAnnotation = GroupName + "/health-check"
+
+// HealthCheck tunes the probes of a load balancer VIP. Interval and Timeout
+// are in seconds. Unset fields fall back to the controller defaults.
+type HealthCheck struct {
+	Interval     int `json:"interval,omitempty"`
+	Timeout      int `json:"timeout,omitempty"`
+	SuccessCount int `json:"successCount,omitempty"`
+	FailureCount int `json:"failureCount,omitempty"`
+}
+
+// PublicIPPoolAnnotation selects the IPAM public pool a LoadBalancer Service,
+// or a pod floating IP, gets its address from, overriding the controller
+// default.
+const PublicIPPoolAnnotation = GroupName + "/public-ip-pool"
+
+// ChassisAnnotation names the OVN chassis (the OVS system-id) of a Node when
+// it differs from the node name.
+const ChassisAnnotation = GroupName + "/chassis"
+
+// Queue annotations shape the traffic delivered to the pod interface with a
+// linux-htb queue on its OVS port. Rates are quantities in bits per second,
+// e.g. "100M"; the priority orders queues sharing a port, lower first.
+const (
+	QueueMinRateAnnotation  = GroupName + "/queue-min-rate"
+	QueueMaxRateAnnotation  = GroupName + "/queue-max-rate"
+	QueuePriorityAnnotation = GroupName + "/queue-priority"
+)
+
+// VhostUserSocketsAnnotation is set by the CNI on pods attached in vhost-user
+// mode: a JSON object of the interface names to the paths of their sockets,
+// named after the host side interface of the VM,
+// e.g. {"net1": "/var/run/ovn-cni/vhostuser/<pod uid>/veth-vm1"}.
+const VhostUserSocketsAnnotation = GroupName + "/vhostuser-sockets"
+
+// Conntrack annotations give the pods of a namespace, or a single VM pod
+// overriding its namespace, their own conntrack budget: the limit is the
+// maximum number of connections in the zone of each interface, the timeouts
+// a JSON object of CT_Timeout_Policy seconds, e.g. {"tcp_established": 3600}.
+const (
+	ConntrackLimitAnnotation    = GroupName + "/conntrack-limit"
+	ConntrackTimeoutsAnnotation = GroupName + "/conntrack-timeouts"
+)
+
+var SecurityGroupResource = SchemeGroupVersion.WithResource("securitygroups")
+
+// SecurityGroup is a set of stateful allow rules, in the style of OpenStack
+// security groups. Traffic not allowed by any group of a port is dropped.
+type SecurityGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SecurityGroupSpec `json:"spec"`
+}
+
+type SecurityGroupSpec struct {
+	Rules []SecurityGroupRule `json:"rules,omitempty"`
+}
+
+type SecurityGroupRule struct {
+	// Direction is "ingress" or "egress".
+	Direction string `json:"direction"`
+	// Protocol is one of "tcp", "udp", "sctp", "icmp" or empty for any.
+	Protocol string `json:"protocol,omitempty"`
+	// PortRangeMin and PortRangeMax bound the destination port for tcp, udp
+	// and sctp. Both unset means any port.
+	PortRangeMin int32 `json:"portRangeMin,omitempty"`
+	PortRangeMax int32 `json:"portRangeMax,omitempty"`
+	// RemoteCIDR and RemoteGroup restrict the peer. RemoteGroup names another
+	// security group in the same namespace. Both unset means any peer.
+	RemoteCIDR  string `json:"remoteCIDR,omitempty"`
+	RemoteGroup string `json:"remoteGroup,omitempty"`
+}
+
+var EgressFirewallResource = SchemeGroupVersion.WithResource("egressfirewalls")
+
+// EgressFirewallName is the only EgressFirewall name honoured in a namespace.
+const EgressFirewallName = "default"
+
+// EgressFirewall restricts the destinations pods of its namespace may reach.
+// Rules are evaluated in order, the first matching rule wins.
+type EgressFirewall struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec EgressFirewallSpec `json:"spec"`
+}
+
+type EgressFirewallSpec struct {
+	Egress []EgressFirewallRule `json:"egress"`
+}
+
+type EgressFirewallRule struct {
+	// Type is "Allow" or "Deny".
+	Type  string                    `json:"type"`
+	To    EgressFirewallDestination `json:"to"`
+	Ports []EgressFirewallPort      `json:"ports,omitempty"`
+}
+
+// EgressFirewallDestination is either a CIDR or a DNS name, which is resolved
+// periodically following the TTL of its records.
+type EgressFirewallDestination struct {
+	CIDRSelector string `json:"cidrSelector,omitempty"`
+	DNSName      string `json:"dnsName,omitempty"`
+}
+
+type EgressFirewallPort struct {
+	// Protocol is "TCP", "UDP" or "SCTP".
+	Protocol string `json:"protocol"`
+	// Port unset means any port of the protocol.
+	Port int32 `json:"port,omitempty"`
+}
+
+var DSCPPolicyResource = SchemeGroupVersion.WithResource("dscppolicies")
+
+// DSCPPolicy marks the traffic sent by the selected pods of its namespace, so
+// that the physical network can prioritize it. Rules are evaluated in order,
+// the first matching rule wins.
+type DSCPPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DSCPPolicySpec `json:"spec"`
+}
+
+type DSCPPolicySpec struct {
+	// PodSelector selects the pods of the namespace; empty selects all.
+	PodSelector metav1.LabelSelector `json:"podSelector"`
+	Rules       []DSCPRule           `json:"rules"`
+}
+
+type DSCPRule struct {
+	// DSCP is the code point, 0 to 63, written in the IP header.
+	DSCP int `json:"dscp"`
+	// Mark optionally sets the packet mark as well, for the host datapath.
+	Mark int `json:"mark,omitempty"`
+	// To restricts the rule to destination CIDRs; empty means any.
+	To    []string             `json:"to,omitempty"`
+	Ports []EgressFirewallPort `json:"ports,omitempty"`
+}
+
+var PortMirrorResource = SchemeGroupVersion.WithResource("portmirrors")
+
+// PortMirror copies the traffic of the selected pods of its namespace to a
+// sink, typically an IDS reached through a GRE or ERSPAN tunnel.
+type PortMirror struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PortMirrorSpec `json:"spec"`
+}
+
+type PortMirrorSpec struct {
+	// PodSelector selects the pods of the namespace; empty selects all.
+	PodSelector metav1.LabelSelector `json:"podSelector"`
+	// Type is "gre", "erspan" or "local".
+	Type string `json:"type"`
+	// Filter is "from-lport", "to-lport" or "both", seen from the pod.
+	Filter string `json:"filter"`
+	// Sink is the tunnel remote IP for gre and erspan, or the name of the
+	// OVS interface receiving the copies for local.
+	Sink string `json:"sink"`
+	// Index is the tunnel key for gre and the session id for erspan.
+	Index int `json:"index,omitempty"`
+}
+
+var SubPortResource = SchemeGroupVersion.WithResource("subports")
+
+// SubPort is a logical port nested in the port of a VM interface, for the
+// containers the VM runs: frames the guest tags with VLAN belong to the
+// sub-port, which gets its own addresses and policies.
+type SubPort struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SubPortSpec `json:"spec"`
+}
+
+type SubPortSpec struct {
+	// VMName is the VM of the namespace the parent interface belongs to.
+	VMName string `json:"vmName"`
+	// Interface is the parent interface inside the VM pod, "eth0" when empty.
+	Interface string `json:"interface,omitempty"`
+	// VLAN is the tag the guest uses for the nested workload, 1 to 4095.
+	VLAN int `json:"vlan"`
+	// MACAddress and IPs pin the addresses of the nested workload. They are
+	// assigned by IPAM; when set they must be the assigned ones.
+	MACAddress string   `json:"macAddress,omitempty"`
+	IPs        []string `json:"ips,omitempty"`
+}
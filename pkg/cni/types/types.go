@@ -1,6 +1,9 @@
 package types
 
-import "github.com/containernetworking/cni/pkg/types"
+import (
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/net_utils"
+)
 
 type CniKubeArgs struct {
 	types.CommonArgs
@@ -16,5 +19,36 @@ type NetConf struct {
 	Bridge        string         `json:"bridge"`        // e.g. "br-int"
 	LogicalSwitch string         `json:"logicalSwitch"` // e.g. "ls-vm-net"
 	OVNNB         string         `json:"ovnNb"`         // e.g. "tcp:192.168.12.177:6641"
+	DNSDomain     string         `json:"dnsDomain"`     // e.g. "vm.cluster.local"
 	IPAM          map[string]any `json:"ipam,omitempty"`
+	RuntimeConfig RuntimeConfig  `json:"runtimeConfig,omitempty"`
+	// ProviderNetwork makes the logical switch a provider network bridged
+	// to this ovn-bridge-mappings network, e.g. "physnet1", on VLAN when
+	// non-zero.
+	ProviderNetwork string `json:"providerNetwork,omitempty"`
+	VLAN            int    `json:"vlan,omitempty"`
+	// VLANPassthru delivers VLAN tagged frames to the guests attached
+	// through this network unchanged, for appliances such as firewalls and
+	// routers. The logical switch passes tagged frames while any such port
+	// exists, so it is left to the operator writing the NetConf.
+	VLANPassthru bool `json:"vlanPassthru,omitempty"`
+	// Mode is how the VM is attached: "veth", the default, or "vhostuser"
+	// for a DPDK vhost-user socket on a netdev bridge. The sockets live in
+	// a per pod directory of VhostUserSocketDir, to be shared with the
+	// virt-launcher pod, whose QEMU group, VhostUserSocketGID, owns them.
+	Mode               string `json:"mode,omitempty"`
+	VhostUserSocketDir string `json:"vhostUserSocketDir,omitempty"`
+	VhostUserSocketGID *int   `json:"vhostUserSocketGID,omitempty"`
+}
+
+// Attachment modes of NetConf.Mode.
+const (
+	ModeVeth      = "veth"
+	ModeVhostUser = "vhostuser"
+)
+
+// RuntimeConfig holds the capabilities the runtime fills in, e.g. the
+// bandwidth capability derived by the kubelet from the pod annotations.
+type RuntimeConfig struct {
+	Bandwidth *net_utils.Bandwidth `json:"bandwidth,omitempty"`
 }
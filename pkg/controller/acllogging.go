@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+)
+
+// aclLoggingMeter rate limits the ACL log messages of ovn-controller.
+const aclLoggingMeter = "acl-logging"
+
+// maxACLNameLen is the length limit of ACL names in the NB schema.
+const maxACLNameLen = 63
+
+// ACL name prefixes, one per kind of owner.
+const (
+	aclKindNetworkPolicy              = "NP"
+	aclKindDefaultDeny                = "NPDeny"
+	aclKindSecurityGroup              = "SG"
+	aclKindAdminNetworkPolicy         = "ANP"
+	aclKindBaselineAdminNetworkPolicy = "BANP"
+	aclKindEgressFirewall             = "EF"
+)
+
+// aclName builds the name ovn-controller prints in ACL log lines:
+// <kind>:<namespace>:<object>:<direction>[:<rule index>], with the namespace
+// omitted for cluster scoped objects. Names longer than the 63 characters
+// allowed by the NB schema are cut and end in a hash of the full
+// name, so that the ACLs of different rules never share a name.
+func aclName(kind string, parts ...string) *string {
+	nonEmpty := []string{kind}
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	name := strings.Join(nonEmpty, ":")
+	if len(name) > maxACLNameLen {
+		// hashedName appends "_" and 16 hex digits.
+		name = hashedName(name[:maxACLNameLen-17], name)
+	}
+	return &name
+}
+
+// namespaceACLLogging returns the ACL logging settings of a namespace.
+func (c *Controller) namespaceACLLogging(namespace string) v1alpha1.ACLLogging {
+	ns, err := c.namespaceLister.Get(namespace)
+	if err != nil {
+		return v1alpha1.ACLLogging{}
+	}
+	return aclLoggingFromAnnotations(ns.Annotations)
+}
+
+func aclLoggingFromAnnotations(annotations map[string]string) v1alpha1.ACLLogging {
+	logging := v1alpha1.ACLLogging{}
+	value, ok := annotations[v1alpha1.ACLLoggingAnnotation]
+	if !ok {
+		return logging
+	}
+	if err := json.Unmarshal([]byte(value), &logging); err != nil {
+		log.Printf("⚠️ Invalid %s annotation %q: %v", v1alpha1.ACLLoggingAnnotation, value, err)
+		return v1alpha1.ACLLogging{}
+	}
+	return logging
+}
+
+// setACLLogging turns on logging of an ACL when a severity is configured for
+// its verdict. Pass verdicts are never logged.
+func setACLLogging(acl *models.ACL, logging v1alpha1.ACLLogging) {
+	var severity string
+	switch acl.Action {
+	case models.ACLActionDrop, models.ACLActionReject:
+		severity = logging.Deny
+	case models.ACLActionAllow, models.ACLActionAllowRelated, models.ACLActionAllowStateless:
+		severity = logging.Allow
+	}
+	switch severity {
+	case models.ACLSeverityAlert, models.ACLSeverityWarning, models.ACLSeverityNotice, models.ACLSeverityInfo, models.ACLSeverityDebug:
+	case "":
+		return
+	default:
+		log.Printf("⚠️ Unknown acl log severity %q, not logging %s", severity, *acl.Name)
+		return
+	}
+
+	meter := aclLoggingMeter
+	acl.Log = true
+	acl.Severity = &severity
+	acl.Meter = &meter
+}
@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	policyv1alpha1 "github.com/cybercoder/ik8s-ovn-cni/pkg/apis/policy/v1alpha1"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	ownerTypeAdminNetworkPolicy         = "admin-network-policy"
+	ownerTypeBaselineAdminNetworkPolicy = "baseline-admin-network-policy"
+
+	// ACL tiers are evaluated in order, a "pass" verdict moves evaluation to
+	// the next tier. Admin policies come before the tenant NetworkPolicies and
+	// security groups, the baseline policy after them.
+	aclTierAdmin         = 1
+	aclTierNetworkPolicy = 2
+	aclTierBaseline      = 3
+
+	// AdminNetworkPolicy priorities 0..maxAdminPriority map onto descending
+	// ACL priorities, leaving room for 100 rules per direction.
+	adminACLPriorityBase    = 30000
+	maxAdminPriority        = 99
+	baselineACLPriorityBase = 1750
+)
+
+// adminPolicy is the part of AdminNetworkPolicy and BaselineAdminNetworkPolicy
+// compiled into ACLs.
+type adminPolicy struct {
+	ownerType string
+	aclKind   string
+	key       string
+	logging   v1alpha1.ACLLogging
+	tier      int
+	priority  int // ACL priority of the first rule
+	subject   policyv1alpha1.AdminNetworkPolicySubject
+	ingress   []policyv1alpha1.AdminNetworkPolicyIngressRule
+	egress    []policyv1alpha1.AdminNetworkPolicyEgressRule
+}
+
+func (c *Controller) enqueueAllAdminNetworkPolicies() {
+	for _, q := range []struct {
+		lister cache.GenericLister
+		queue  func(string)
+	}{
+		{c.anpLister, c.anpQueue.Add},
+		{c.banpLister, c.banpQueue.Add},
+	} {
+		policies, err := q.lister.List(labels.Everything())
+		if err != nil {
+			log.Printf("failed to list admin network policies: %v", err)
+			continue
+		}
+		for _, obj := range policies {
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			if err != nil {
+				continue
+			}
+			q.queue(key)
+		}
+	}
+}
+
+func (c *Controller) syncAdminNetworkPolicy(key string) error {
+	obj, err := c.anpLister.Get(key)
+	if errors.IsNotFound(err) {
+		return c.deleteOwnedRows(ownerTypeAdminNetworkPolicy, key)
+	}
+	if err != nil {
+		return err
+	}
+	anp := &policyv1alpha1.AdminNetworkPolicy{}
+	if err := v1alpha1.FromUnstructured(obj, anp); err != nil {
+		return fmt.Errorf("failed to decode admin network policy %s: %v", key, err)
+	}
+	if anp.Spec.Priority < 0 || anp.Spec.Priority > maxAdminPriority {
+		log.Printf("⚠️ Admin network policy %s priority %d is out of the supported range 0-%d, ignoring it", key, anp.Spec.Priority, maxAdminPriority)
+		return c.deleteOwnedRows(ownerTypeAdminNetworkPolicy, key)
+	}
+	return c.syncAdminPolicy(adminPolicy{
+		ownerType: ownerTypeAdminNetworkPolicy,
+		aclKind:   aclKindAdminNetworkPolicy,
+		key:       key,
+		logging:   aclLoggingFromAnnotations(anp.Annotations),
+		tier:      aclTierAdmin,
+		priority:  adminACLPriorityBase - int(anp.Spec.Priority)*100,
+		subject:   anp.Spec.Subject,
+		ingress:   anp.Spec.Ingress,
+		egress:    anp.Spec.Egress,
+	})
+}
+
+func (c *Controller) syncBaselineAdminNetworkPolicy(key string) error {
+	obj, err := c.banpLister.Get(key)
+	if errors.IsNotFound(err) {
+		return c.deleteOwnedRows(ownerTypeBaselineAdminNetworkPolicy, key)
+	}
+	if err != nil {
+		return err
+	}
+	banp := &policyv1alpha1.BaselineAdminNetworkPolicy{}
+	if err := v1alpha1.FromUnstructured(obj, banp); err != nil {
+		return fmt.Errorf("failed to decode baseline admin network policy %s: %v", key, err)
+	}
+	return c.syncAdminPolicy(adminPolicy{
+		ownerType: ownerTypeBaselineAdminNetworkPolicy,
+		aclKind:   aclKindBaselineAdminNetworkPolicy,
+		key:       key,
+		logging:   aclLoggingFromAnnotations(banp.Annotations),
+		tier:      aclTierBaseline,
+		priority:  baselineACLPriorityBase,
+		subject:   banp.Spec.Subject,
+		ingress:   banp.Spec.Ingress,
+		egress:    banp.Spec.Egress,
+	})
+}
+
+func (c *Controller) syncAdminPolicy(p adminPolicy) error {
+	ports, err := c.listPodPorts()
+	if err != nil {
+		return err
+	}
+	subject, err := c.selectAdminPeer(ports, p.subject.Namespaces, p.subject.Pods)
+	if err != nil {
+		return err
+	}
+
+	pgName := hashedName("anp", p.ownerType, p.key)
+	if err := c.ovnClient.EnsurePortGroup(pgName, ownerIDs(p.ownerType, p.key), portUUIDs(subject)); err != nil {
+		return err
+	}
+
+	acls := []*models.ACL{}
+	addressSets := map[string]bool{}
+	for i, rule := range p.ingress {
+		acl, err := c.adminRuleACL(p, pgName, directionIngress, i, rule.Name, rule.Action, rule.From, rule.Ports, ports, addressSets)
+		if err != nil {
+			log.Printf("⚠️ Skipping ingress rule %d of %s %s: %v", i, p.ownerType, p.key, err)
+			continue
+		}
+		acls = append(acls, acl)
+	}
+	for i, rule := range p.egress {
+		acl, err := c.adminRuleACL(p, pgName, directionEgress, i, rule.Name, rule.Action, rule.To, rule.Ports, ports, addressSets)
+		if err != nil {
+			log.Printf("⚠️ Skipping egress rule %d of %s %s: %v", i, p.ownerType, p.key, err)
+			continue
+		}
+		acls = append(acls, acl)
+	}
+	for _, acl := range acls {
+		setACLLogging(acl, p.logging)
+	}
+	if err := c.ovnClient.SetPortGroupACLs(pgName, acls); err != nil {
+		return err
+	}
+	return c.deleteStaleAddressSets(p.ownerType, p.key, addressSets)
+}
+
+func (c *Controller) adminRuleACL(p adminPolicy, pgName string, dir policyDirection, idx int, name, action string, peers []policyv1alpha1.AdminNetworkPolicyPeer, rulePorts *[]policyv1alpha1.AdminNetworkPolicyPort, ports []podPort, addressSets map[string]bool) (*models.ACL, error) {
+	var aclAction models.ACLAction
+	switch action {
+	case policyv1alpha1.RuleActionAllow:
+		aclAction = models.ACLActionAllowRelated
+	case policyv1alpha1.RuleActionDeny:
+		aclAction = models.ACLActionDrop
+	case policyv1alpha1.RuleActionPass:
+		if p.ownerType == ownerTypeBaselineAdminNetworkPolicy {
+			return nil, fmt.Errorf("action Pass is not valid in a baseline policy")
+		}
+		aclAction = models.ACLActionPass
+	default:
+		return nil, fmt.Errorf("unknown action %q", action)
+	}
+	if idx >= 100 {
+		return nil, fmt.Errorf("only 100 rules per direction are supported")
+	}
+
+	remote := "src"
+	match := []string{fmt.Sprintf("outport == @%s", pgName), "ip"}
+	aclDir := models.ACLDirectionToLport
+	if dir == directionEgress {
+		remote = "dst"
+		match[0] = fmt.Sprintf("inport == @%s", pgName)
+		aclDir = models.ACLDirectionFromLport
+	}
+
+	peerMatches := []string{}
+	for i, peer := range peers {
+		switch {
+		case len(peer.Networks) > 0:
+			for _, cidr := range peer.Networks {
+				peerMatches = append(peerMatches, fmt.Sprintf("%s.%s == %s", ipFamily(cidr), remote, cidr))
+			}
+		case peer.Namespaces != nil || peer.Pods != nil:
+			selected, err := c.selectAdminPeer(ports, peer.Namespaces, peer.Pods)
+			if err != nil {
+				return nil, err
+			}
+			ips := []string{}
+			for _, sp := range selected {
+				if sp.IP != "" {
+					ips = append(ips, sp.IP)
+				}
+			}
+			asName := hashedName("anp", p.ownerType, p.key, string(dir), fmt.Sprint(idx), fmt.Sprint(i))
+			ids := ownerIDs(p.ownerType, p.key, externalIDDirection, string(dir))
+			if err := c.ovnClient.EnsureAddressSet(asName, ids, ips); err != nil {
+				return nil, err
+			}
+			addressSets[asName] = true
+			peerMatches = append(peerMatches, fmt.Sprintf("ip4.%s == $%s", remote, asName))
+		default:
+			log.Printf("⚠️ Unsupported peer in rule %q of %s %s, skipping it", name, p.ownerType, p.key)
+		}
+	}
+	if len(peerMatches) == 0 {
+		return nil, fmt.Errorf("rule %q has no supported peers", name)
+	}
+	match = append(match, "("+strings.Join(peerMatches, " || ")+")")
+
+	if rulePorts != nil {
+		portMatches := []string{}
+		for _, port := range *rulePorts {
+			switch {
+			case port.PortNumber != nil:
+				proto := strings.ToLower(port.PortNumber.Protocol)
+				portMatches = append(portMatches, fmt.Sprintf("%s.dst == %d", proto, port.PortNumber.Port))
+			case port.PortRange != nil:
+				proto := strings.ToLower(port.PortRange.Protocol)
+				if proto == "" {
+					proto = "tcp"
+				}
+				portMatches = append(portMatches, "("+portRangeMatch(proto, port.PortRange.Start, port.PortRange.End)+")")
+			default:
+				log.Printf("⚠️ Named ports are not supported in rule %q of %s %s, skipping it", name, p.ownerType, p.key)
+			}
+		}
+		if len(portMatches) == 0 {
+			return nil, fmt.Errorf("rule %q has no supported ports", name)
+		}
+		match = append(match, "("+strings.Join(portMatches, " || ")+")")
+	}
+
+	return &models.ACL{
+		Name:        aclName(p.aclKind, p.key, string(dir), fmt.Sprint(idx)),
+		Action:      aclAction,
+		Direction:   aclDir,
+		Priority:    p.priority - idx,
+		Tier:        p.tier,
+		Match:       strings.Join(match, " && "),
+		ExternalIDs: ownerIDs(p.ownerType, p.key, externalIDDirection, string(dir)),
+	}, nil
+}
+
+// selectAdminPeer returns the pod ports selected by a subject or peer given
+// either as a namespace selector or as namespace and pod selectors.
+func (c *Controller) selectAdminPeer(ports []podPort, namespaces *metav1.LabelSelector, pods *policyv1alpha1.NamespacedPod) ([]podPort, error) {
+	nsSelector, podSelector := labels.Nothing(), labels.Everything()
+	var err error
+	switch {
+	case namespaces != nil:
+		if nsSelector, err = metav1.LabelSelectorAsSelector(namespaces); err != nil {
+			return nil, err
+		}
+	case pods != nil:
+		if nsSelector, err = metav1.LabelSelectorAsSelector(&pods.NamespaceSelector); err != nil {
+			return nil, err
+		}
+		if podSelector, err = metav1.LabelSelectorAsSelector(&pods.PodSelector); err != nil {
+			return nil, err
+		}
+	}
+	matching, err := c.namespacesMatching(nsSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []podPort{}
+	for _, p := range ports {
+		if matching[p.Pod.Namespace] && podSelector.Matches(labels.Set(p.Pod.Labels)) {
+			selected = append(selected, p)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,492 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	policyv1alpha1 "github.com/cybercoder/ik8s-ovn-cni/pkg/apis/policy/v1alpha1"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// External ids used to find the NB rows owned by a Kubernetes object.
+const (
+	ExternalIDOwnerType = ovnnb.ExternalIDOwnerType
+	ExternalIDOwner     = ovnnb.ExternalIDOwner
+)
+
+// Options tunes the controller.
+type Options struct {
+	// ACLLogRate and ACLLogBurst limit, in packets per second, the ACL log
+	// messages of each ovn-controller.
+	ACLLogRate  int
+	ACLLogBurst int
+	// ClusterCIDRs are the in-cluster destinations egress firewalls ignore.
+	ClusterCIDRs []string
+	// FloatingIPRouter is the tenant router holding floating IP NAT rules.
+	// Floating IPs are disabled when empty.
+	FloatingIPRouter string
+	// ServiceSwitches and ServiceRouters get the load balancers of the
+	// cluster services.
+	ServiceSwitches []string
+	ServiceRouters  []string
+	// LogicalSwitch holds the pod ports and their QoS rules.
+	LogicalSwitch string
+	// HealthCheckSourceIP is an unused address of the service switches that
+	// OVN probes load balancer backends from. Health checks are disabled
+	// when empty.
+	HealthCheckSourceIP string
+	// HealthCheck holds the probe defaults of services that turn health
+	// checks on.
+	HealthCheck v1alpha1.HealthCheck
+	// GatewayRouter exposes LoadBalancer services on external IPs taken
+	// from PublicIPPool. LoadBalancer services are disabled when empty.
+	GatewayRouter string
+	PublicIPPool  string
+	// CoppRates limits, in packets per second keyed by ovnnb.CoppProtocols,
+	// the control plane packets of the managed switches and routers.
+	CoppRates map[string]int
+}
+
+// Controller translates Kubernetes objects into OVN northbound state.
+type Controller struct {
+	opts Options
+
+	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+	ovnClient     *ovnnb.Client
+
+	informerFactory        informers.SharedInformerFactory
+	dynamicInformerFactory dynamicinformer.DynamicSharedInformerFactory
+	podLister              corelisters.PodLister
+	namespaceLister        corelisters.NamespaceLister
+	npLister               networkinglisters.NetworkPolicyLister
+	sgLister               cache.GenericLister
+	anpLister              cache.GenericLister
+	banpLister             cache.GenericLister
+	efLister               cache.GenericLister
+	dscpLister             cache.GenericLister
+	mirrorLister           cache.GenericLister
+	subPortLister          cache.GenericLister
+	serviceLister          corelisters.ServiceLister
+	endpointSliceLister    discoverylisters.EndpointSliceLister
+	nodeLister             corelisters.NodeLister
+	synced                 []cache.InformerSynced
+
+	npQueue      workqueue.TypedRateLimitingInterface[string]
+	sgQueue      workqueue.TypedRateLimitingInterface[string]
+	anpQueue     workqueue.TypedRateLimitingInterface[string]
+	banpQueue    workqueue.TypedRateLimitingInterface[string]
+	efQueue      workqueue.TypedRateLimitingInterface[string]
+	fipQueue     workqueue.TypedRateLimitingInterface[string]
+	svcQueue     workqueue.TypedRateLimitingInterface[string]
+	nodeQueue    workqueue.TypedRateLimitingInterface[string]
+	dscpQueue    workqueue.TypedRateLimitingInterface[string]
+	mirrorQueue  workqueue.TypedRateLimitingInterface[string]
+	subPortQueue workqueue.TypedRateLimitingInterface[string]
+	workers      []worker
+
+	dnsNames *dnsNameCache
+}
+
+// worker drains a queue with the sync function of its resource.
+type worker struct {
+	queue workqueue.TypedRateLimitingInterface[string]
+	sync  func(key string) error
+}
+
+func NewController(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface, ovnClient *ovnnb.Client, opts Options) (*Controller, error) {
+	factory := informers.NewSharedInformerFactory(kubeClient, 0)
+	dynamicFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, 0)
+	podInformer := factory.Core().V1().Pods()
+	namespaceInformer := factory.Core().V1().Namespaces()
+	npInformer := factory.Networking().V1().NetworkPolicies()
+	sgInformer := dynamicFactory.ForResource(v1alpha1.SecurityGroupResource)
+	anpInformer := dynamicFactory.ForResource(policyv1alpha1.AdminNetworkPolicyResource)
+	banpInformer := dynamicFactory.ForResource(policyv1alpha1.BaselineAdminNetworkPolicyResource)
+	efInformer := dynamicFactory.ForResource(v1alpha1.EgressFirewallResource)
+	dscpInformer := dynamicFactory.ForResource(v1alpha1.DSCPPolicyResource)
+	mirrorInformer := dynamicFactory.ForResource(v1alpha1.PortMirrorResource)
+	subPortInformer := dynamicFactory.ForResource(v1alpha1.SubPortResource)
+	serviceInformer := factory.Core().V1().Services()
+	endpointSliceInformer := factory.Discovery().V1().EndpointSlices()
+	nodeInformer := factory.Core().V1().Nodes()
+
+	c := &Controller{
+		opts:                   opts,
+		kubeClient:             kubeClient,
+		dynamicClient:          dynamicClient,
+		ovnClient:              ovnClient,
+		informerFactory:        factory,
+		dynamicInformerFactory: dynamicFactory,
+		podLister:              podInformer.Lister(),
+		namespaceLister:        namespaceInformer.Lister(),
+		npLister:               npInformer.Lister(),
+		sgLister:               sgInformer.Lister(),
+		anpLister:              anpInformer.Lister(),
+		banpLister:             banpInformer.Lister(),
+		efLister:               efInformer.Lister(),
+		dscpLister:             dscpInformer.Lister(),
+		mirrorLister:           mirrorInformer.Lister(),
+		subPortLister:          subPortInformer.Lister(),
+		serviceLister:          serviceInformer.Lister(),
+		endpointSliceLister:    endpointSliceInformer.Lister(),
+		nodeLister:             nodeInformer.Lister(),
+		synced: []cache.InformerSynced{
+			podInformer.Informer().HasSynced,
+			namespaceInformer.Informer().HasSynced,
+			npInformer.Informer().HasSynced,
+			sgInformer.Informer().HasSynced,
+			anpInformer.Informer().HasSynced,
+			banpInformer.Informer().HasSynced,
+			efInformer.Informer().HasSynced,
+			dscpInformer.Informer().HasSynced,
+			mirrorInformer.Informer().HasSynced,
+			subPortInformer.Informer().HasSynced,
+			serviceInformer.Informer().HasSynced,
+			endpointSliceInformer.Informer().HasSynced,
+			nodeInformer.Informer().HasSynced,
+		},
+		npQueue:      newQueue("network-policy"),
+		sgQueue:      newQueue("security-group"),
+		anpQueue:     newQueue("admin-network-policy"),
+		banpQueue:    newQueue("baseline-admin-network-policy"),
+		efQueue:      newQueue("egress-firewall"),
+		fipQueue:     newQueue("floating-ip"),
+		svcQueue:     newQueue("service"),
+		nodeQueue:    newQueue("node"),
+		dscpQueue:    newQueue("dscp-policy"),
+		mirrorQueue:  newQueue("port-mirror"),
+		subPortQueue: newQueue("sub-port"),
+		dnsNames:     &dnsNameCache{entries: map[string]dnsEntry{}, lookups: map[string]chan struct{}{}},
+	}
+	c.workers = []worker{
+		{queue: c.npQueue, sync: c.syncNetworkPolicy},
+		{queue: c.sgQueue, sync: c.syncSecurityGroup},
+		{queue: c.anpQueue, sync: c.syncAdminNetworkPolicy},
+		{queue: c.banpQueue, sync: c.syncBaselineAdminNetworkPolicy},
+		{queue: c.efQueue, sync: c.syncEgressFirewall},
+		{queue: c.fipQueue, sync: c.syncFloatingIP},
+		{queue: c.svcQueue, sync: c.syncService},
+		{queue: c.nodeQueue, sync: c.syncNode},
+		{queue: c.dscpQueue, sync: c.syncDSCPPolicy},
+		{queue: c.mirrorQueue, sync: c.syncPortMirror},
+		{queue: c.subPortQueue, sync: c.syncSubPort},
+	}
+
+	if _, err := npInformer.Informer().AddEventHandler(enqueueHandler(c.npQueue)); err != nil {
+		return nil, err
+	}
+	if _, err := anpInformer.Informer().AddEventHandler(enqueueHandler(c.anpQueue)); err != nil {
+		return nil, err
+	}
+	if _, err := banpInformer.Informer().AddEventHandler(enqueueHandler(c.banpQueue)); err != nil {
+		return nil, err
+	}
+	if _, err := efInformer.Informer().AddEventHandler(enqueueHandler(c.efQueue)); err != nil {
+		return nil, err
+	}
+	if _, err := dscpInformer.Informer().AddEventHandler(enqueueHandler(c.dscpQueue)); err != nil {
+		return nil, err
+	}
+	if _, err := mirrorInformer.Informer().AddEventHandler(enqueueHandler(c.mirrorQueue)); err != nil {
+		return nil, err
+	}
+	if _, err := subPortInformer.Informer().AddEventHandler(enqueueHandler(c.subPortQueue)); err != nil {
+		return nil, err
+	}
+	if _, err := podInformer.Informer().AddEventHandler(enqueueHandler(c.fipQueue)); err != nil {
+		return nil, err
+	}
+	if _, err := serviceInformer.Informer().AddEventHandler(enqueueHandler(c.svcQueue)); err != nil {
+		return nil, err
+	}
+	if _, err := endpointSliceInformer.Informer().AddEventHandler(c.endpointSliceHandler()); err != nil {
+		return nil, err
+	}
+	if _, err := nodeInformer.Informer().AddEventHandler(enqueueHandler(c.nodeQueue)); err != nil {
+		return nil, err
+	}
+	// Remote group rules depend on the other groups of the namespace.
+	if _, err := sgInformer.Informer().AddEventHandler(resyncHandler(c.enqueueAllSecurityGroups)); err != nil {
+		return nil, err
+	}
+	// Selectors and annotations may start or stop matching whenever pods or
+	// namespaces change.
+	if _, err := podInformer.Informer().AddEventHandler(resyncHandler(c.enqueueAll)); err != nil {
+		return nil, err
+	}
+	if _, err := namespaceInformer.Informer().AddEventHandler(resyncHandler(c.enqueueAll)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run starts the informers and workers and blocks until ctx is cancelled.
+func (c *Controller) Run(ctx context.Context, workers int) error {
+	for _, w := range c.workers {
+		defer w.queue.ShutDown()
+	}
+
+	if err := c.ovnClient.EnsureMeter(aclLoggingMeter, models.MeterUnitPktps, c.opts.ACLLogRate, c.opts.ACLLogBurst); err != nil {
+		return err
+	}
+	if err := c.ensureServiceLBGroup(); err != nil {
+		return err
+	}
+	if err := c.ensureCopp(); err != nil {
+		return err
+	}
+
+	c.informerFactory.Start(ctx.Done())
+	c.dynamicInformerFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), c.synced...) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+	if err := c.enqueueStale(c.npQueue, ownerTypeNetworkPolicy); err != nil {
+		return err
+	}
+	if err := c.enqueueStale(c.sgQueue, ownerTypeSecurityGroup); err != nil {
+		return err
+	}
+	if err := c.enqueueStale(c.anpQueue, ownerTypeAdminNetworkPolicy); err != nil {
+		return err
+	}
+	if err := c.enqueueStale(c.banpQueue, ownerTypeBaselineAdminNetworkPolicy); err != nil {
+		return err
+	}
+	if err := c.enqueueStaleEgressFirewalls(); err != nil {
+		return err
+	}
+	if err := c.enqueueStaleFloatingIPs(); err != nil {
+		return err
+	}
+	if err := c.enqueueStaleServices(); err != nil {
+		return err
+	}
+	if err := c.enqueueStaleNodes(); err != nil {
+		return err
+	}
+	if err := c.enqueueStale(c.dscpQueue, ownerTypeDSCPPolicy); err != nil {
+		return err
+	}
+	if err := c.enqueueStalePortMirrors(); err != nil {
+		return err
+	}
+	if err := c.enqueueStaleSubPorts(); err != nil {
+		return err
+	}
+
+	for _, w := range c.workers {
+		for range workers {
+			go wait.UntilWithContext(ctx, func(ctx context.Context) {
+				for processNextItem(w.queue, w.sync) {
+				}
+			}, time.Second)
+		}
+	}
+	// Pod ports are created by the CNI outside the informers' view, so
+	// periodically reconcile everything.
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		c.enqueueAll()
+	}, time.Minute)
+
+	log.Printf("✅ Controller started with %d workers", workers)
+	<-ctx.Done()
+	return nil
+}
+
+func (c *Controller) enqueueAll() {
+	c.enqueueAllNetworkPolicies()
+	c.enqueueAllSecurityGroups()
+	c.enqueueAllAdminNetworkPolicies()
+	c.enqueueAllEgressFirewalls()
+	c.enqueueAllFloatingIPs()
+	c.enqueueAllServices()
+	c.enqueueAllDSCPPolicies()
+	c.enqueueAllPortMirrors()
+	c.enqueueAllSubPorts()
+}
+
+// enqueueStale queues the owners of port groups of the given owner type so
+// that objects deleted while the controller was down get cleaned up.
+func (c *Controller) enqueueStale(queue workqueue.TypedRateLimitingInterface[string], ownerType string) error {
+	pgs, err := c.ovnClient.ListPortGroups(ExternalIDOwnerType, ownerType)
+	if err != nil {
+		return err
+	}
+	for _, pg := range pgs {
+		queue.Add(pg.ExternalIDs[ExternalIDOwner])
+	}
+	return nil
+}
+
+func resyncHandler(enqueue func()) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { enqueue() },
+		UpdateFunc: func(any, any) { enqueue() },
+		DeleteFunc: func(any) { enqueue() },
+	}
+}
+
+func newQueue(name string) workqueue.TypedRateLimitingInterface[string] {
+	return workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.DefaultTypedControllerRateLimiter[string](),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: name},
+	)
+}
+
+func enqueueHandler(queue workqueue.TypedRateLimitingInterface[string]) cache.ResourceEventHandlerFuncs {
+	enqueue := func(obj any) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			log.Printf("failed to get key for object: %v", err)
+			return
+		}
+		queue.Add(key)
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, obj any) { enqueue(obj) },
+		DeleteFunc: enqueue,
+	}
+}
+
+func processNextItem(queue workqueue.TypedRateLimitingInterface[string], sync func(key string) error) bool {
+	key, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+	defer queue.Done(key)
+
+	if err := sync(key); err != nil {
+		log.Printf("error syncing %s: %v", key, err)
+		queue.AddRateLimited(key)
+		return true
+	}
+	queue.Forget(key)
+	return true
+}
+
+// podPort is a logical switch port created by the CNI, joined with its pod.
+type podPort struct {
+	UUID string
+	Name string
+	MAC  string
+	IP   string
+	Pod  *corev1.Pod
+	// SubPort is set on the ports nested in a VM interface.
+	SubPort bool
+}
+
+// listPodPorts returns the logical switch ports of pods that still exist.
+func (c *Controller) listPodPorts() ([]podPort, error) {
+	lsps, err := c.ovnClient.ListPodLogicalPorts()
+	if err != nil {
+		return nil, err
+	}
+	ports := []podPort{}
+	for _, lsp := range lsps {
+		pod, err := c.podLister.Pods(lsp.ExternalIDs[ovnnb.ExternalIDNamespace]).Get(lsp.ExternalIDs[ovnnb.ExternalIDPod])
+		if err != nil {
+			continue
+		}
+		mac := ""
+		if len(lsp.Addresses) > 0 {
+			mac = strings.Fields(lsp.Addresses[0] + " ")[0]
+		}
+		ports = append(ports, podPort{
+			UUID:    lsp.UUID,
+			Name:    lsp.Name,
+			MAC:     mac,
+			IP:      lsp.ExternalIDs[ovnnb.ExternalIDIP],
+			Pod:     pod,
+			SubPort: lsp.ParentName != nil,
+		})
+	}
+	return ports, nil
+}
+
+// namespacesMatching returns the names of namespaces whose labels match selector.
+func (c *Controller) namespacesMatching(selector labels.Selector) (map[string]bool, error) {
+	namespaces, err := c.namespaceLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, ns := range namespaces {
+		names[ns.Name] = true
+	}
+	return names, nil
+}
+
+// deleteOwnedRows removes the port groups and address sets owned by an object.
+func (c *Controller) deleteOwnedRows(ownerType, owner string) error {
+	pgs, err := c.ovnClient.ListPortGroups(ExternalIDOwnerType, ownerType)
+	if err != nil {
+		return err
+	}
+	for _, pg := range pgs {
+		if pg.ExternalIDs[ExternalIDOwner] != owner {
+			continue
+		}
+		if err := c.ovnClient.DeletePortGroup(pg.Name); err != nil {
+			return err
+		}
+	}
+	return c.deleteStaleAddressSets(ownerType, owner, nil)
+}
+
+// deleteStaleAddressSets removes the address sets owned by an object that are
+// not listed in keep.
+func (c *Controller) deleteStaleAddressSets(ownerType, owner string, keep map[string]bool) error {
+	sets, err := c.ovnClient.ListAddressSets(ExternalIDOwnerType, ownerType)
+	if err != nil {
+		return err
+	}
+	for _, as := range sets {
+		if as.ExternalIDs[ExternalIDOwner] != owner || keep[as.Name] {
+			continue
+		}
+		if err := c.ovnClient.DeleteAddressSet(as.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ownerIDs(ownerType, owner string, extra ...string) map[string]string {
+	ids := map[string]string{
+		ExternalIDOwnerType: ownerType,
+		ExternalIDOwner:     owner,
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		ids[extra[i]] = extra[i+1]
+	}
+	return ids
+}
+
+// hashedName builds an OVN identifier (port group or address set name) from
+// arbitrary parts. OVN names may only hold letters, digits, '_' and '.'.
+func hashedName(prefix string, parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "/")))
+	return prefix + "_" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+)
+
+// coppName is the control plane protection policy of the switches and
+// routers the plugin manages.
+const coppName = "ovn-cni"
+
+func coppMeterName(protocol string) string { return "copp-" + protocol }
+
+// ensureCopp rate limits, per protocol, the packets ovn-controller handles
+// for the managed switches and routers, so a flooding VM cannot overload it.
+// Protocols without a rate are not limited; no rates at all removes the
+// policy.
+func (c *Controller) ensureCopp() error {
+	meters := map[string]string{}
+	for _, protocol := range ovnnb.CoppProtocols {
+		rate := c.opts.CoppRates[protocol]
+		if rate <= 0 {
+			if err := c.ovnClient.DeleteMeter(coppMeterName(protocol)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.ovnClient.EnsureMeter(coppMeterName(protocol), models.MeterUnitPktps, rate, rate); err != nil {
+			return err
+		}
+		meters[protocol] = coppMeterName(protocol)
+	}
+	if len(meters) == 0 {
+		return c.ovnClient.DeleteCopp(coppName)
+	}
+	if err := c.ovnClient.EnsureCopp(coppName, meters, nil); err != nil {
+		return err
+	}
+
+	switches := append([]string{c.opts.LogicalSwitch}, c.opts.ServiceSwitches...)
+	routers := append([]string{c.opts.GatewayRouter, c.opts.FloatingIPRouter}, c.opts.ServiceRouters...)
+	seen := map[string]bool{}
+	for _, ls := range switches {
+		if ls == "" || seen["ls/"+ls] {
+			continue
+		}
+		seen["ls/"+ls] = true
+		if err := c.ovnClient.SetLogicalSwitchCopp(ls, coppName); err != nil {
+			return err
+		}
+	}
+	for _, lr := range routers {
+		if lr == "" || seen["lr/"+lr] {
+			continue
+		}
+		seen["lr/"+lr] = true
+		if err := c.ovnClient.SetLogicalRouterCopp(lr, coppName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
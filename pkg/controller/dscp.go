@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	ownerTypeDSCPPolicy = "dscp-policy"
+
+	// Rule i of a policy gets priorityDSCP - i, so that the first matching
+	// rule wins.
+	priorityDSCP = 2000
+)
+
+func (c *Controller) enqueueAllDSCPPolicies() {
+	policies, err := c.dscpLister.List(labels.Everything())
+	if err != nil {
+		log.Printf("failed to list dscp policies: %v", err)
+		return
+	}
+	for _, obj := range policies {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			continue
+		}
+		c.dscpQueue.Add(key)
+	}
+}
+
+// syncDSCPPolicy renders a DSCPPolicy as QoS rules on the pod switch matching
+// the traffic sent by the port group of the selected pods.
+func (c *Controller) syncDSCPPolicy(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	owned := func(r *models.QoS) bool {
+		return r.ExternalIDs[ExternalIDOwnerType] == ownerTypeDSCPPolicy && r.ExternalIDs[ExternalIDOwner] == key
+	}
+
+	obj, err := c.dscpLister.ByNamespace(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		if err := c.ovnClient.ReplaceSwitchQoS(c.opts.LogicalSwitch, owned, nil); err != nil {
+			return err
+		}
+		return c.deleteOwnedRows(ownerTypeDSCPPolicy, key)
+	}
+	if err != nil {
+		return err
+	}
+	policy := &v1alpha1.DSCPPolicy{}
+	if err := v1alpha1.FromUnstructured(obj, policy); err != nil {
+		return fmt.Errorf("failed to decode dscp policy %s: %v", key, err)
+	}
+
+	ports, err := c.listPodPorts()
+	if err != nil {
+		return err
+	}
+	selected, err := selectPorts(ports, namespace, policy.Spec.PodSelector)
+	if err != nil {
+		log.Printf("⚠️ Invalid pod selector of dscp policy %s: %v", key, err)
+		return nil
+	}
+	pgName := hashedName("dscp", key)
+	if err := c.ovnClient.EnsurePortGroup(pgName, ownerIDs(ownerTypeDSCPPolicy, key), portUUIDs(selected)); err != nil {
+		return err
+	}
+
+	rules := []*models.QoS{}
+	for i, rule := range policy.Spec.Rules {
+		qos, err := dscpRuleQoS(key, pgName, i, rule)
+		if err != nil {
+			log.Printf("⚠️ Skipping rule of dscp policy %s: %v", key, err)
+			continue
+		}
+		rules = append(rules, qos)
+	}
+	return c.ovnClient.ReplaceSwitchQoS(c.opts.LogicalSwitch, owned, rules)
+}
+
+func dscpRuleQoS(key, pgName string, idx int, rule v1alpha1.DSCPRule) (*models.QoS, error) {
+	if rule.DSCP < 0 || rule.DSCP > 63 {
+		return nil, fmt.Errorf("dscp %d out of range", rule.DSCP)
+	}
+	match := []string{fmt.Sprintf("inport == @%s", pgName), "ip"}
+
+	dstMatches := []string{}
+	for _, cidr := range rule.To {
+		dstMatches = append(dstMatches, fmt.Sprintf("%s.dst == %s", ipFamily(cidr), cidr))
+	}
+	if len(dstMatches) > 0 {
+		match = append(match, "("+strings.Join(dstMatches, " || ")+")")
+	}
+
+	portMatches := []string{}
+	for _, port := range rule.Ports {
+		proto := strings.ToLower(port.Protocol)
+		switch proto {
+		case "tcp", "udp", "sctp":
+		default:
+			return nil, fmt.Errorf("unsupported protocol %q", port.Protocol)
+		}
+		if port.Port == 0 {
+			portMatches = append(portMatches, proto)
+		} else {
+			portMatches = append(portMatches, fmt.Sprintf("%s.dst == %d", proto, port.Port))
+		}
+	}
+	if len(portMatches) > 0 {
+		match = append(match, "("+strings.Join(portMatches, " || ")+")")
+	}
+
+	action := map[string]int{"dscp": rule.DSCP}
+	if rule.Mark > 0 {
+		action["mark"] = rule.Mark
+	}
+	return &models.QoS{
+		Direction:   models.QoSDirectionFromLport,
+		Priority:    priorityDSCP - idx,
+		Match:       strings.Join(match, " && "),
+		Action:      action,
+		ExternalIDs: ownerIDs(ownerTypeDSCPPolicy, key),
+	}, nil
+}
@@ -0,0 +1,267 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/net_utils"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	ownerTypeEgressFirewall = "egress-firewall"
+
+	// Egress firewall rules share the NetworkPolicy tier and take precedence
+	// over policy ACLs; rule i gets priorityEgressFirewall - i.
+	priorityEgressFirewall = 10000
+	maxEgressFirewallRules = 1000
+
+	// Bounds on how often a DNS name of a rule is resolved again.
+	dnsMinTTL = 30 * time.Second
+	dnsMaxTTL = 30 * time.Minute
+)
+
+// dnsNameCache holds the resolved addresses of egress firewall DNS names
+// until their TTL expires.
+type dnsNameCache struct {
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+	// lookups holds a channel per name being resolved, closed once the
+	// lookup is done, so that concurrent syncs wait for a single query.
+	lookups map[string]chan struct{}
+}
+
+type dnsEntry struct {
+	ips     []string
+	expires time.Time
+}
+
+// resolve returns the cached addresses of name, resolving it again once the
+// TTL has expired, and how long the returned addresses stay valid. The
+// lookup runs without the lock held, so that a slow name does not hold up
+// the others.
+func (d *dnsNameCache) resolve(name string) ([]string, time.Duration) {
+	d.mu.Lock()
+	for {
+		now := time.Now()
+		entry, ok := d.entries[name]
+		if ok && !now.After(entry.expires) {
+			d.mu.Unlock()
+			return entry.ips, entry.expires.Sub(now)
+		}
+		done, inFlight := d.lookups[name]
+		if !inFlight {
+			break
+		}
+		d.mu.Unlock()
+		<-done
+		d.mu.Lock()
+	}
+	done := make(chan struct{})
+	d.lookups[name] = done
+	d.mu.Unlock()
+
+	ips, ttl, err := net_utils.ResolveIPv4WithTTL(name)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.lookups, name)
+	close(done)
+	now := time.Now()
+	if err != nil {
+		// keep the previous addresses and retry soon
+		log.Printf("⚠️ Failed to resolve %s: %v", name, err)
+		ttl = dnsMinTTL
+		ips = d.entries[name].ips
+	}
+	ttl = min(max(ttl, dnsMinTTL), dnsMaxTTL)
+	entry := dnsEntry{ips: ips, expires: now.Add(ttl)}
+	d.entries[name] = entry
+	return entry.ips, entry.expires.Sub(now)
+}
+
+func (c *Controller) enqueueAllEgressFirewalls() {
+	firewalls, err := c.efLister.List(labels.Everything())
+	if err != nil {
+		log.Printf("failed to list egress firewalls: %v", err)
+		return
+	}
+	for _, obj := range firewalls {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			continue
+		}
+		c.efQueue.Add(key)
+	}
+}
+
+// enqueueStaleEgressFirewalls queues the firewall of every namespace so that
+// ACLs of firewalls deleted while the controller was down get cleaned up.
+func (c *Controller) enqueueStaleEgressFirewalls() error {
+	namespaces, err := c.namespaceLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, ns := range namespaces {
+		c.efQueue.Add(ns.Name + "/" + v1alpha1.EgressFirewallName)
+	}
+	return nil
+}
+
+func (c *Controller) syncEgressFirewall(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	if name != v1alpha1.EgressFirewallName {
+		log.Printf("⚠️ Ignoring egress firewall %s, only %q is honoured", key, v1alpha1.EgressFirewallName)
+		return nil
+	}
+
+	pgName := ovnnb.NamespacePortGroupName(namespace)
+	owned := func(acl *models.ACL) bool {
+		return acl.ExternalIDs[ExternalIDOwnerType] == ownerTypeEgressFirewall
+	}
+
+	obj, err := c.efLister.ByNamespace(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		pgs, err := c.ovnClient.ListPortGroups(ovnnb.ExternalIDNamespace, namespace)
+		if err != nil {
+			return err
+		}
+		for _, pg := range pgs {
+			if pg.Name == pgName {
+				if err := c.ovnClient.ReplacePortGroupACLs(pgName, owned, nil); err != nil {
+					return err
+				}
+			}
+		}
+		return c.deleteStaleAddressSets(ownerTypeEgressFirewall, key, nil)
+	}
+	if err != nil {
+		return err
+	}
+	ef := &v1alpha1.EgressFirewall{}
+	if err := v1alpha1.FromUnstructured(obj, ef); err != nil {
+		return fmt.Errorf("failed to decode egress firewall %s: %v", key, err)
+	}
+
+	if err := c.ovnClient.EnsureNamespacePortGroup(namespace); err != nil {
+		return err
+	}
+
+	acls := []*models.ACL{}
+	addressSets := map[string]bool{}
+	var refresh time.Duration
+	for i, rule := range ef.Spec.Egress {
+		if i >= maxEgressFirewallRules {
+			log.Printf("⚠️ Egress firewall %s has more than %d rules, ignoring the rest", key, maxEgressFirewallRules)
+			break
+		}
+		acl, ttl, err := c.egressFirewallRuleACL(key, pgName, i, rule, addressSets)
+		if err != nil {
+			log.Printf("⚠️ Skipping rule %d of egress firewall %s: %v", i, key, err)
+			continue
+		}
+		if ttl > 0 && (refresh == 0 || ttl < refresh) {
+			refresh = ttl
+		}
+		acls = append(acls, acl)
+	}
+
+	logging := c.namespaceACLLogging(namespace)
+	for _, acl := range acls {
+		setACLLogging(acl, logging)
+	}
+	if err := c.ovnClient.ReplacePortGroupACLs(pgName, owned, acls); err != nil {
+		return err
+	}
+	if err := c.deleteStaleAddressSets(ownerTypeEgressFirewall, key, addressSets); err != nil {
+		return err
+	}
+	if refresh > 0 {
+		c.efQueue.AddAfter(key, refresh)
+	}
+	return nil
+}
+
+// egressFirewallRuleACL renders one rule. For DNS name destinations it also
+// returns how long the resolved addresses are valid.
+func (c *Controller) egressFirewallRuleACL(key, pgName string, idx int, rule v1alpha1.EgressFirewallRule, addressSets map[string]bool) (*models.ACL, time.Duration, error) {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+
+	var action models.ACLAction
+	switch rule.Type {
+	case "Allow":
+		action = models.ACLActionAllowRelated
+	case "Deny":
+		action = models.ACLActionDrop
+	default:
+		return nil, 0, fmt.Errorf("unknown type %q", rule.Type)
+	}
+
+	match := []string{fmt.Sprintf("inport == @%s", pgName), "ip"}
+	var ttl time.Duration
+	family := "ip4"
+	switch {
+	case rule.To.CIDRSelector != "":
+		family = ipFamily(rule.To.CIDRSelector)
+		match = append(match, fmt.Sprintf("%s.dst == %s", family, rule.To.CIDRSelector))
+	case rule.To.DNSName != "":
+		var ips []string
+		ips, ttl = c.dnsNames.resolve(rule.To.DNSName)
+		asName := hashedName("ef", key, rule.To.DNSName)
+		ids := ownerIDs(ownerTypeEgressFirewall, key, "ovn.ik8s.ir/dns-name", rule.To.DNSName)
+		if err := c.ovnClient.EnsureAddressSet(asName, ids, ips); err != nil {
+			return nil, 0, err
+		}
+		addressSets[asName] = true
+		match = append(match, fmt.Sprintf("ip4.dst == $%s", asName))
+	default:
+		return nil, 0, fmt.Errorf("no destination")
+	}
+
+	// The firewall only applies to traffic leaving the cluster. A match on
+	// the other family would require it and never match the rule's traffic.
+	for _, cidr := range c.opts.ClusterCIDRs {
+		if ipFamily(cidr) == family {
+			match = append(match, fmt.Sprintf("%s.dst != %s", family, cidr))
+		}
+	}
+
+	portMatches := []string{}
+	for _, port := range rule.Ports {
+		proto := strings.ToLower(port.Protocol)
+		switch proto {
+		case "tcp", "udp", "sctp":
+		default:
+			return nil, 0, fmt.Errorf("unsupported protocol %q", port.Protocol)
+		}
+		if port.Port == 0 {
+			portMatches = append(portMatches, proto)
+		} else {
+			portMatches = append(portMatches, fmt.Sprintf("%s.dst == %d", proto, port.Port))
+		}
+	}
+	if len(portMatches) > 0 {
+		match = append(match, "("+strings.Join(portMatches, " || ")+")")
+	}
+
+	return &models.ACL{
+		Name:        aclName(aclKindEgressFirewall, namespace, name, string(directionEgress), fmt.Sprint(idx)),
+		Action:      action,
+		Direction:   models.ACLDirectionFromLport,
+		Priority:    priorityEgressFirewall - idx,
+		Tier:        aclTierNetworkPolicy,
+		Match:       strings.Join(match, " && "),
+		ExternalIDs: ownerIDs(ownerTypeEgressFirewall, key, externalIDDirection, string(directionEgress)),
+	}, ttl, nil
+}
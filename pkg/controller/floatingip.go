@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"log"
+	"net"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const ownerTypeFloatingIP = "floating-ip"
+
+func (c *Controller) enqueueAllFloatingIPs() {
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		log.Printf("failed to list pods: %v", err)
+		return
+	}
+	for _, pod := range pods {
+		if _, ok := pod.Annotations[v1alpha1.FloatingIPAnnotation]; !ok {
+			continue
+		}
+		key, err := cache.MetaNamespaceKeyFunc(pod)
+		if err != nil {
+			continue
+		}
+		c.fipQueue.Add(key)
+	}
+}
+
+// enqueueStaleFloatingIPs queues the pods owning NAT rules so that floating
+// IPs of pods deleted while the controller was down get released.
+func (c *Controller) enqueueStaleFloatingIPs() error {
+	if c.opts.FloatingIPRouter == "" {
+		return nil
+	}
+	nats, err := c.ovnClient.ListNATs(c.opts.FloatingIPRouter)
+	if err != nil {
+		return err
+	}
+	for _, nat := range nats {
+		if nat.ExternalIDs[ExternalIDOwnerType] == ownerTypeFloatingIP {
+			c.fipQueue.Add(nat.ExternalIDs[ExternalIDOwner])
+		}
+	}
+	return nil
+}
+
+// syncFloatingIP keeps the dnat_and_snat rule of a pod in line with its
+// floating IP annotation.
+func (c *Controller) syncFloatingIP(key string) error {
+	router := c.opts.FloatingIPRouter
+	if router == "" {
+		return nil
+	}
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	nats, err := c.ovnClient.ListNATs(router)
+	if err != nil {
+		return err
+	}
+
+	var desired *models.NAT
+	// The public pool address stays assigned to the pod while it asks for a
+	// floating IP, even without a NAT rule, so that it gets the same one.
+	release := true
+	pod, err := c.podLister.Pods(namespace).Get(name)
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		_, requested := pod.Annotations[v1alpha1.FloatingIPAnnotation]
+		release = !requested
+		if desired, err = c.podFloatingIPNAT(key, pod.Annotations, nats); err != nil {
+			return err
+		}
+	}
+	for _, nat := range nats {
+		if nat.ExternalIDs[ExternalIDOwnerType] != ownerTypeFloatingIP || nat.ExternalIDs[ExternalIDOwner] != key {
+			continue
+		}
+		if desired != nil && nat.ExternalIP == desired.ExternalIP {
+			continue
+		}
+		// Released before the rule goes, so that a failed release is retried.
+		if release {
+			if err := c.releasePublicPoolIP(namespace, name, "floating-ip"); err != nil {
+				return err
+			}
+			release = false
+		}
+		if err := c.ovnClient.DeleteNAT(router, nat.Type, nat.ExternalIP); err != nil {
+			return err
+		}
+	}
+	if desired == nil {
+		return nil
+	}
+	return c.ovnClient.AddOrUpdateNAT(router, desired)
+}
+
+// podFloatingIPNAT builds the NAT rule requested by the pod annotations, or
+// returns nil when the pod has no floating IP, no attached port yet, or asks
+// for an IP it does not hold.
+func (c *Controller) podFloatingIPNAT(key string, annotations map[string]string, nats []models.NAT) (*models.NAT, error) {
+	requested, ok := annotations[v1alpha1.FloatingIPAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	if requested != "auto" && net.ParseIP(requested) == nil {
+		log.Printf("⚠️ Invalid floating ip %q on pod %s", requested, key)
+		return nil, nil
+	}
+	externalIP, err := c.podFloatingIP(key, requested, annotations, nats)
+	if err != nil || externalIP == "" {
+		return nil, err
+	}
+
+	ports, err := c.listPodPorts()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ports {
+		if p.Pod.Namespace+"/"+p.Pod.Name != key || p.IP == "" || p.SubPort {
+			continue
+		}
+		nat := &models.NAT{
+			Type:        models.NATTypeDNATAndSNAT,
+			ExternalIP:  externalIP,
+			LogicalIP:   p.IP,
+			ExternalIDs: ownerIDs(ownerTypeFloatingIP, key),
+		}
+		if annotations[v1alpha1.FloatingIPDistributedAnnotation] == "true" && p.MAC != "" {
+			nat.LogicalPort = &p.Name
+			nat.ExternalMAC = &p.MAC
+		}
+		return nat, nil
+	}
+	return nil, nil
+}
+
+// podFloatingIP returns the floating IP of the pod: the one of its current
+// NAT rule, or the address IPAM assigns to it. An IP held by another owner,
+// or differing from the IPAM assignment, is refused.
+func (c *Controller) podFloatingIP(key, requested string, annotations map[string]string, nats []models.NAT) (string, error) {
+	for _, nat := range nats {
+		if nat.ExternalIDs[ExternalIDOwnerType] != ownerTypeFloatingIP {
+			continue
+		}
+		owner := nat.ExternalIDs[ExternalIDOwner]
+		if owner != key && nat.ExternalIP == requested {
+			log.Printf("⚠️ Floating ip %s requested by pod %s is held by %s", requested, key, owner)
+			return "", nil
+		}
+		if owner == key && (requested == "auto" || nat.ExternalIP == requested) {
+			return nat.ExternalIP, nil
+		}
+	}
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return "", err
+	}
+	assigned, err := c.publicPoolIP(namespace, name, "floating-ip", annotations)
+	if err != nil {
+		return "", err
+	}
+	if requested != "auto" && requested != assigned {
+		log.Printf("⚠️ Floating ip %s requested by pod %s is not its public pool address %s", requested, key, assigned)
+		return "", nil
+	}
+	return assigned, nil
+}
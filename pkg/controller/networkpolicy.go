@@ -0,0 +1,349 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	ownerTypeNetworkPolicy = "network-policy"
+	ownerTypeDefaultDeny   = "network-policy-default-deny"
+
+	externalIDDirection = "ovn.ik8s.ir/direction"
+
+	// Policy ACLs are evaluated above the per-namespace default deny.
+	priorityDefaultDeny = 1000
+	priorityPolicyAllow = 1001
+)
+
+type policyDirection string
+
+const (
+	directionIngress policyDirection = "ingress"
+	directionEgress  policyDirection = "egress"
+)
+
+func (c *Controller) enqueueAllNetworkPolicies() {
+	policies, err := c.npLister.List(labels.Everything())
+	if err != nil {
+		log.Printf("failed to list network policies: %v", err)
+		return
+	}
+	for _, np := range policies {
+		key, err := cache.MetaNamespaceKeyFunc(np)
+		if err != nil {
+			continue
+		}
+		c.npQueue.Add(key)
+	}
+}
+
+func (c *Controller) syncNetworkPolicy(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	np, err := c.npLister.NetworkPolicies(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		if err := c.deleteOwnedRows(ownerTypeNetworkPolicy, key); err != nil {
+			return err
+		}
+		return c.syncDefaultDeny(namespace)
+	}
+	if err != nil {
+		return err
+	}
+
+	ports, err := c.listPodPorts()
+	if err != nil {
+		return err
+	}
+	selected, err := selectPorts(ports, namespace, np.Spec.PodSelector)
+	if err != nil {
+		return err
+	}
+
+	pgName := hashedName("np", key)
+	if err := c.ovnClient.EnsurePortGroup(pgName, ownerIDs(ownerTypeNetworkPolicy, key), portUUIDs(selected)); err != nil {
+		return err
+	}
+
+	acls := []*models.ACL{}
+	addressSets := map[string]bool{}
+	ingress, egress := policyTypes(np)
+	if ingress {
+		for i, rule := range np.Spec.Ingress {
+			acl, err := c.policyRuleACL(key, pgName, directionIngress, i, rule.From, rule.Ports, ports, addressSets)
+			if err != nil {
+				return err
+			}
+			acls = append(acls, acl)
+		}
+	}
+	if egress {
+		for i, rule := range np.Spec.Egress {
+			acl, err := c.policyRuleACL(key, pgName, directionEgress, i, rule.To, rule.Ports, ports, addressSets)
+			if err != nil {
+				return err
+			}
+			acls = append(acls, acl)
+		}
+	}
+	logging := c.namespaceACLLogging(namespace)
+	for _, acl := range acls {
+		setACLLogging(acl, logging)
+	}
+	if err := c.ovnClient.SetPortGroupACLs(pgName, acls); err != nil {
+		return err
+	}
+	if err := c.deleteStaleAddressSets(ownerTypeNetworkPolicy, key, addressSets); err != nil {
+		return err
+	}
+	return c.syncDefaultDeny(namespace)
+}
+
+// policyRuleACL renders one ingress or egress rule as an allow-related ACL.
+// Selector peers are backed by address sets, which are created on the way and
+// recorded in addressSets.
+func (c *Controller) policyRuleACL(key, pgName string, dir policyDirection, idx int, peers []networkingv1.NetworkPolicyPeer, npPorts []networkingv1.NetworkPolicyPort, ports []podPort, addressSets map[string]bool) (*models.ACL, error) {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	remote := "src"
+	match := []string{fmt.Sprintf("outport == @%s", pgName), "ip"}
+	aclDir := models.ACLDirectionToLport
+	if dir == directionEgress {
+		remote = "dst"
+		match[0] = fmt.Sprintf("inport == @%s", pgName)
+		aclDir = models.ACLDirectionFromLport
+	}
+
+	peerMatches := []string{}
+	for i, peer := range peers {
+		if peer.IPBlock != nil {
+			peerMatches = append(peerMatches, ipBlockMatch(remote, peer.IPBlock))
+			continue
+		}
+		ips, err := c.peerIPs(namespace, peer, ports)
+		if err != nil {
+			return nil, err
+		}
+		asName := hashedName("np", key, string(dir), fmt.Sprint(idx), fmt.Sprint(i))
+		ids := ownerIDs(ownerTypeNetworkPolicy, key, externalIDDirection, string(dir))
+		if err := c.ovnClient.EnsureAddressSet(asName, ids, ips); err != nil {
+			return nil, err
+		}
+		addressSets[asName] = true
+		peerMatches = append(peerMatches, fmt.Sprintf("ip4.%s == $%s", remote, asName))
+	}
+	if len(peerMatches) > 0 {
+		match = append(match, "("+strings.Join(peerMatches, " || ")+")")
+	}
+	if portMatches := policyPortsMatch(npPorts); portMatches != "" {
+		match = append(match, portMatches)
+	}
+
+	return &models.ACL{
+		Name:        aclName(aclKindNetworkPolicy, namespace, name, string(dir), fmt.Sprint(idx)),
+		Action:      models.ACLActionAllowRelated,
+		Direction:   aclDir,
+		Priority:    priorityPolicyAllow,
+		Tier:        aclTierNetworkPolicy,
+		Match:       strings.Join(match, " && "),
+		ExternalIDs: ownerIDs(ownerTypeNetworkPolicy, key, externalIDDirection, string(dir)),
+	}, nil
+}
+
+// peerIPs resolves a pod/namespace selector peer to pod IPs.
+func (c *Controller) peerIPs(namespace string, peer networkingv1.NetworkPolicyPeer, ports []podPort) ([]string, error) {
+	namespaces := map[string]bool{namespace: true}
+	if peer.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		if namespaces, err = c.namespacesMatching(selector); err != nil {
+			return nil, err
+		}
+	}
+	podSelector := labels.Everything()
+	if peer.PodSelector != nil {
+		var err error
+		if podSelector, err = metav1.LabelSelectorAsSelector(peer.PodSelector); err != nil {
+			return nil, err
+		}
+	}
+
+	ips := []string{}
+	for _, p := range ports {
+		if p.IP == "" || !namespaces[p.Pod.Namespace] || !podSelector.Matches(labels.Set(p.Pod.Labels)) {
+			continue
+		}
+		ips = append(ips, p.IP)
+	}
+	return ips, nil
+}
+
+// syncDefaultDeny keeps the namespace port groups that drop all traffic not
+// allowed by a policy, one per direction, in line with the namespace policies.
+func (c *Controller) syncDefaultDeny(namespace string) error {
+	policies, err := c.npLister.NetworkPolicies(namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	ports, err := c.listPodPorts()
+	if err != nil {
+		return err
+	}
+
+	isolated := map[policyDirection]map[string]bool{
+		directionIngress: {},
+		directionEgress:  {},
+	}
+	for _, np := range policies {
+		selected, err := selectPorts(ports, namespace, np.Spec.PodSelector)
+		if err != nil {
+			return err
+		}
+		ingress, egress := policyTypes(np)
+		for _, p := range selected {
+			if ingress {
+				isolated[directionIngress][p.UUID] = true
+			}
+			if egress {
+				isolated[directionEgress][p.UUID] = true
+			}
+		}
+	}
+
+	logging := c.namespaceACLLogging(namespace)
+	for dir, members := range isolated {
+		pgName := hashedName("deny", namespace, string(dir))
+		if len(members) == 0 {
+			if err := c.ovnClient.DeletePortGroup(pgName); err != nil {
+				return err
+			}
+			continue
+		}
+		uuids := []string{}
+		for u := range members {
+			uuids = append(uuids, u)
+		}
+		ids := ownerIDs(ownerTypeDefaultDeny, namespace, externalIDDirection, string(dir))
+		if err := c.ovnClient.EnsurePortGroup(pgName, ids, uuids); err != nil {
+			return err
+		}
+		acl := &models.ACL{
+			Name:        aclName(aclKindDefaultDeny, namespace, string(dir)),
+			Action:      models.ACLActionDrop,
+			Direction:   models.ACLDirectionToLport,
+			Priority:    priorityDefaultDeny,
+			Tier:        aclTierNetworkPolicy,
+			Match:       fmt.Sprintf("outport == @%s && ip", pgName),
+			ExternalIDs: ids,
+		}
+		if dir == directionEgress {
+			acl.Direction = models.ACLDirectionFromLport
+			acl.Match = fmt.Sprintf("inport == @%s && ip", pgName)
+		}
+		setACLLogging(acl, logging)
+		if err := c.ovnClient.SetPortGroupACLs(pgName, []*models.ACL{acl}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// policyTypes reports whether a policy isolates ingress and/or egress, with the
+// defaulting rules of the NetworkPolicy API.
+func policyTypes(np *networkingv1.NetworkPolicy) (ingress, egress bool) {
+	if len(np.Spec.PolicyTypes) == 0 {
+		return true, len(np.Spec.Egress) > 0
+	}
+	for _, t := range np.Spec.PolicyTypes {
+		switch t {
+		case networkingv1.PolicyTypeIngress:
+			ingress = true
+		case networkingv1.PolicyTypeEgress:
+			egress = true
+		}
+	}
+	return ingress, egress
+}
+
+func selectPorts(ports []podPort, namespace string, podSelector metav1.LabelSelector) ([]podPort, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&podSelector)
+	if err != nil {
+		return nil, err
+	}
+	selected := []podPort{}
+	for _, p := range ports {
+		if p.Pod.Namespace == namespace && selector.Matches(labels.Set(p.Pod.Labels)) {
+			selected = append(selected, p)
+		}
+	}
+	return selected, nil
+}
+
+func portUUIDs(ports []podPort) []string {
+	uuids := []string{}
+	for _, p := range ports {
+		uuids = append(uuids, p.UUID)
+	}
+	return uuids
+}
+
+func ipBlockMatch(remote string, block *networkingv1.IPBlock) string {
+	family := ipFamily(block.CIDR)
+	match := fmt.Sprintf("%s.%s == %s", family, remote, block.CIDR)
+	for _, except := range block.Except {
+		match += fmt.Sprintf(" && %s.%s != %s", family, remote, except)
+	}
+	return "(" + match + ")"
+}
+
+func ipFamily(cidr string) string {
+	ip, _, err := net.ParseCIDR(cidr)
+	if err == nil && ip.To4() == nil {
+		return "ip6"
+	}
+	return "ip4"
+}
+
+// policyPortsMatch renders the destination ports of a rule. Named ports are
+// not supported since VM ports have no container port names.
+func policyPortsMatch(npPorts []networkingv1.NetworkPolicyPort) string {
+	matches := []string{}
+	for _, p := range npPorts {
+		proto := "tcp"
+		if p.Protocol != nil {
+			proto = strings.ToLower(string(*p.Protocol))
+		}
+		switch {
+		case p.Port == nil:
+			matches = append(matches, proto)
+		case p.Port.StrVal != "":
+			log.Printf("⚠️ Named port %q is not supported, skipping", p.Port.StrVal)
+		case p.EndPort != nil:
+			matches = append(matches, fmt.Sprintf("(%s.dst >= %d && %s.dst <= %d)", proto, p.Port.IntVal, proto, *p.EndPort))
+		default:
+			matches = append(matches, fmt.Sprintf("%s.dst == %d", proto, p.Port.IntVal))
+		}
+	}
+	if len(matches) == 0 {
+		if len(npPorts) > 0 {
+			// only unsupported ports were given: match nothing
+			return "0"
+		}
+		return ""
+	}
+	return "(" + strings.Join(matches, " || ") + ")"
+}
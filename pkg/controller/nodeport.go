@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	ownerTypeNode = "node"
+	// nodeIPVar is the chassis template variable holding the node IP that
+	// NodePort load balancers listen on, referenced as "^NODEIP".
+	nodeIPVar = "NODEIP"
+)
+
+// enqueueStaleNodes queues the nodes owning chassis template variables so
+// that nodes deleted while the controller was down get cleaned up.
+func (c *Controller) enqueueStaleNodes() error {
+	vars, err := c.ovnClient.ListChassisTemplateVars(ExternalIDOwnerType, ownerTypeNode)
+	if err != nil {
+		return err
+	}
+	for _, tv := range vars {
+		c.nodeQueue.Add(tv.ExternalIDs[ExternalIDOwner])
+	}
+	return nil
+}
+
+// syncNode publishes the IP of a node as the NODEIP template variable of its
+// chassis.
+func (c *Controller) syncNode(name string) error {
+	chassis, nodeIP := "", ""
+	node, err := c.nodeLister.Get(name)
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		chassis = nodeChassis(node)
+		nodeIP = nodeInternalIP(node)
+		if nodeIP == "" {
+			log.Printf("⚠️ Node %s has no IPv4 internal address, skipping NodePort template vars", name)
+		}
+	}
+
+	vars, err := c.ovnClient.ListChassisTemplateVars(ExternalIDOwnerType, ownerTypeNode)
+	if err != nil {
+		return err
+	}
+	for _, tv := range vars {
+		if tv.ExternalIDs[ExternalIDOwner] != name || (tv.Chassis == chassis && nodeIP != "") {
+			continue
+		}
+		if err := c.ovnClient.DeleteChassisTemplateVars(tv.Chassis, []string{ExternalIDOwnerType, ExternalIDOwner}, []string{nodeIPVar}); err != nil {
+			return err
+		}
+	}
+	if chassis == "" || nodeIP == "" {
+		return nil
+	}
+	return c.ovnClient.EnsureChassisTemplateVars(chassis, ownerIDs(ownerTypeNode, name), map[string]string{
+		nodeIPVar: nodeIP,
+	})
+}
+
+// nodeChassis returns the OVN chassis name of a node, which defaults to the
+// node name.
+func nodeChassis(node *corev1.Node) string {
+	if chassis := node.Annotations[v1alpha1.ChassisAnnotation]; chassis != "" {
+		return chassis
+	}
+	return node.Name
+}
+
+func nodeInternalIP(node *corev1.Node) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type != corev1.NodeInternalIP {
+			continue
+		}
+		if ip := net.ParseIP(addr.Address); ip != nil && ip.To4() != nil {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+// setNodePortLoadBalancers adds one templated load balancer per protocol of
+// the service, listening on the node ports of every node through ^NODEIP.
+// Each chassis resolves the template to its own node IP.
+func (c *Controller) setNodePortLoadBalancers(key string, svc *corev1.Service, endpointSlices []*discoveryv1.EndpointSlice, lbs map[string]*serviceLB) {
+	if svc.Spec.Type != corev1.ServiceTypeNodePort && svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return
+	}
+	for _, sp := range svc.Spec.Ports {
+		if sp.NodePort == 0 {
+			continue
+		}
+		protocol := strings.ToLower(string(sp.Protocol))
+		if protocol == "" {
+			protocol = models.LoadBalancerProtocolTCP
+		}
+		lbName := "Service_" + key + "_" + protocol + "_nodeport"
+		d := lbs[lbName]
+		if d == nil {
+			options := serviceLBOptions(svc)
+			options["template"] = "true"
+			options["address-family"] = "ipv4"
+			d = &serviceLB{lb: &models.LoadBalancer{
+				Name:        lbName,
+				Protocol:    &protocol,
+				Vips:        map[string]string{},
+				Options:     options,
+				ExternalIDs: ownerIDs(ownerTypeService, key),
+			}}
+			lbs[lbName] = d
+		}
+		backends := serviceBackends(endpointSlices, sp, discoveryv1.AddressTypeIPv4)
+		d.lb.Vips["^"+nodeIPVar+":"+strconv.Itoa(int(sp.NodePort))] = strings.Join(backends, ",")
+	}
+}
+
+// isTemplateLoadBalancer reports whether the VIPs of the load balancer are
+// chassis templates such as the NodePort ones, rather than literal addresses.
+func isTemplateLoadBalancer(lb *models.LoadBalancer) bool {
+	return lb.Options["template"] == "true"
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const ownerTypePortMirror = "port-mirror"
+
+func (c *Controller) enqueueAllPortMirrors() {
+	mirrors, err := c.mirrorLister.List(labels.Everything())
+	if err != nil {
+		log.Printf("failed to list port mirrors: %v", err)
+		return
+	}
+	for _, obj := range mirrors {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			continue
+		}
+		c.mirrorQueue.Add(key)
+	}
+}
+
+// enqueueStalePortMirrors queues the owners of mirrors so that port mirrors
+// deleted while the controller was down get cleaned up.
+func (c *Controller) enqueueStalePortMirrors() error {
+	mirrors, err := c.ovnClient.ListMirrors(ExternalIDOwnerType, ownerTypePortMirror)
+	if err != nil {
+		return err
+	}
+	for _, m := range mirrors {
+		c.mirrorQueue.Add(m.ExternalIDs[ExternalIDOwner])
+	}
+	return nil
+}
+
+// syncPortMirror keeps the NB mirror of a PortMirror attached to the ports of
+// the selected pods. Ports of deleted pods drop the mirror with the port.
+func (c *Controller) syncPortMirror(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	mirrorName := hashedName("mirror", key)
+
+	obj, err := c.mirrorLister.ByNamespace(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return c.ovnClient.DeleteMirror(mirrorName)
+	}
+	if err != nil {
+		return err
+	}
+	pm := &v1alpha1.PortMirror{}
+	if err := v1alpha1.FromUnstructured(obj, pm); err != nil {
+		return fmt.Errorf("failed to decode port mirror %s: %v", key, err)
+	}
+
+	switch pm.Spec.Type {
+	case models.MirrorTypeGre, models.MirrorTypeErspan, models.MirrorTypeLocal:
+	default:
+		log.Printf("⚠️ Invalid type %q of port mirror %s", pm.Spec.Type, key)
+		return c.ovnClient.DeleteMirror(mirrorName)
+	}
+	switch pm.Spec.Filter {
+	case models.MirrorFilterFromLport, models.MirrorFilterToLport, models.MirrorFilterBoth:
+	default:
+		log.Printf("⚠️ Invalid filter %q of port mirror %s", pm.Spec.Filter, key)
+		return c.ovnClient.DeleteMirror(mirrorName)
+	}
+
+	ports, err := c.listPodPorts()
+	if err != nil {
+		return err
+	}
+	selected, err := selectPorts(ports, namespace, pm.Spec.PodSelector)
+	if err != nil {
+		log.Printf("⚠️ Invalid pod selector of port mirror %s: %v", key, err)
+		return nil
+	}
+	return c.ovnClient.EnsureMirror(&models.Mirror{
+		Name:        mirrorName,
+		Type:        pm.Spec.Type,
+		Filter:      pm.Spec.Filter,
+		Sink:        pm.Spec.Sink,
+		Index:       pm.Spec.Index,
+		ExternalIDs: ownerIDs(ownerTypePortMirror, key),
+	}, portUUIDs(selected))
+}
@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	ownerTypeSecurityGroup = "security-group"
+
+	// Security groups sit below NetworkPolicy: a namespace default deny still
+	// wins over a group rule, while policy allows win over the group drop.
+	prioritySecurityGroupDrop  = 900
+	prioritySecurityGroupAllow = 901
+)
+
+func (c *Controller) enqueueAllSecurityGroups() {
+	groups, err := c.sgLister.List(labels.Everything())
+	if err != nil {
+		log.Printf("failed to list security groups: %v", err)
+		return
+	}
+	for _, obj := range groups {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			continue
+		}
+		c.sgQueue.Add(key)
+	}
+}
+
+func (c *Controller) getSecurityGroup(namespace, name string) (*v1alpha1.SecurityGroup, error) {
+	obj, err := c.sgLister.ByNamespace(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	sg := &v1alpha1.SecurityGroup{}
+	if err := v1alpha1.FromUnstructured(obj, sg); err != nil {
+		return nil, fmt.Errorf("failed to decode security group %s/%s: %v", namespace, name, err)
+	}
+	return sg, nil
+}
+
+func (c *Controller) syncSecurityGroup(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	sg, err := c.getSecurityGroup(namespace, name)
+	if errors.IsNotFound(err) {
+		return c.deleteOwnedRows(ownerTypeSecurityGroup, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	ports, err := c.listPodPorts()
+	if err != nil {
+		return err
+	}
+	members := []podPort{}
+	ips := []string{}
+	for _, p := range ports {
+		if p.Pod.Namespace != namespace || !slices.Contains(podSecurityGroups(p.Pod.Annotations), name) {
+			continue
+		}
+		members = append(members, p)
+		if p.IP != "" {
+			ips = append(ips, p.IP)
+		}
+	}
+
+	// The address set lets rules of other groups reference this one.
+	pgName := hashedName("sg", key)
+	ids := ownerIDs(ownerTypeSecurityGroup, key)
+	if err := c.ovnClient.EnsureAddressSet(pgName, ids, ips); err != nil {
+		return err
+	}
+	if err := c.ovnClient.EnsurePortGroup(pgName, ids, portUUIDs(members)); err != nil {
+		return err
+	}
+
+	acls := []*models.ACL{
+		{
+			Name:        aclName(aclKindSecurityGroup, namespace, name, string(directionIngress)),
+			Action:      models.ACLActionDrop,
+			Direction:   models.ACLDirectionToLport,
+			Priority:    prioritySecurityGroupDrop,
+			Tier:        aclTierNetworkPolicy,
+			Match:       fmt.Sprintf("outport == @%s && ip", pgName),
+			ExternalIDs: ownerIDs(ownerTypeSecurityGroup, key, externalIDDirection, string(directionIngress)),
+		},
+		{
+			Name:        aclName(aclKindSecurityGroup, namespace, name, string(directionEgress)),
+			Action:      models.ACLActionDrop,
+			Direction:   models.ACLDirectionFromLport,
+			Priority:    prioritySecurityGroupDrop,
+			Tier:        aclTierNetworkPolicy,
+			Match:       fmt.Sprintf("inport == @%s && ip", pgName),
+			ExternalIDs: ownerIDs(ownerTypeSecurityGroup, key, externalIDDirection, string(directionEgress)),
+		},
+	}
+	for i, rule := range sg.Spec.Rules {
+		acl, err := c.securityGroupRuleACL(key, pgName, i, rule)
+		if err != nil {
+			log.Printf("⚠️ Skipping rule of security group %s: %v", key, err)
+			continue
+		}
+		acls = append(acls, acl)
+	}
+	logging := c.namespaceACLLogging(namespace)
+	for _, acl := range acls {
+		setACLLogging(acl, logging)
+	}
+	return c.ovnClient.SetPortGroupACLs(pgName, acls)
+}
+
+func (c *Controller) securityGroupRuleACL(key, pgName string, idx int, rule v1alpha1.SecurityGroupRule) (*models.ACL, error) {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+	dir := policyDirection(rule.Direction)
+	remote := "src"
+	match := []string{fmt.Sprintf("outport == @%s", pgName), "ip"}
+	aclDir := models.ACLDirectionToLport
+	switch dir {
+	case directionIngress:
+	case directionEgress:
+		remote = "dst"
+		match[0] = fmt.Sprintf("inport == @%s", pgName)
+		aclDir = models.ACLDirectionFromLport
+	default:
+		return nil, fmt.Errorf("invalid direction %q", rule.Direction)
+	}
+
+	switch proto := strings.ToLower(rule.Protocol); proto {
+	case "", "any":
+	case "icmp":
+		match = append(match, "icmp4")
+	case "tcp", "udp", "sctp":
+		match = append(match, proto)
+		if portMatch := portRangeMatch(proto, rule.PortRangeMin, rule.PortRangeMax); portMatch != "" {
+			match = append(match, portMatch)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", rule.Protocol)
+	}
+
+	switch {
+	case rule.RemoteCIDR != "":
+		match = append(match, fmt.Sprintf("%s.%s == %s", ipFamily(rule.RemoteCIDR), remote, rule.RemoteCIDR))
+	case rule.RemoteGroup != "":
+		if _, err := c.getSecurityGroup(namespace, rule.RemoteGroup); err != nil {
+			return nil, fmt.Errorf("remote group %q: %v", rule.RemoteGroup, err)
+		}
+		match = append(match, fmt.Sprintf("ip4.%s == $%s", remote, hashedName("sg", namespace+"/"+rule.RemoteGroup)))
+	}
+
+	return &models.ACL{
+		Name:        aclName(aclKindSecurityGroup, namespace, name, string(dir), fmt.Sprint(idx)),
+		Action:      models.ACLActionAllowRelated,
+		Direction:   aclDir,
+		Priority:    prioritySecurityGroupAllow,
+		Tier:        aclTierNetworkPolicy,
+		Match:       strings.Join(match, " && "),
+		ExternalIDs: ownerIDs(ownerTypeSecurityGroup, key, externalIDDirection, string(dir)),
+	}, nil
+}
+
+func portRangeMatch(proto string, lo, hi int32) string {
+	switch {
+	case lo == 0 && hi == 0:
+		return ""
+	case hi == 0 || lo == hi:
+		return fmt.Sprintf("%s.dst == %d", proto, lo)
+	case lo == 0:
+		return fmt.Sprintf("%s.dst <= %d", proto, hi)
+	default:
+		return fmt.Sprintf("%s.dst >= %d && %s.dst <= %d", proto, lo, proto, hi)
+	}
+}
+
+// podSecurityGroups returns the security group names listed in the pod annotations.
+func podSecurityGroups(annotations map[string]string) []string {
+	groups := []string{}
+	for _, name := range strings.Split(annotations[v1alpha1.SecurityGroupsAnnotation], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			groups = append(groups, name)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,348 @@
+package controller
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	ownerTypeService = "service"
+	// serviceLBGroup holds the load balancers of all services and is applied
+	// to the switches and routers listed in the controller options.
+	serviceLBGroup = "cluster-services"
+)
+
+// ensureServiceLBGroup creates the service load balancer group and applies it
+// to the configured switches and routers.
+func (c *Controller) ensureServiceLBGroup() error {
+	if err := c.ovnClient.EnsureLoadBalancerGroup(serviceLBGroup); err != nil {
+		return err
+	}
+	for _, ls := range c.opts.ServiceSwitches {
+		if err := c.ovnClient.AddLoadBalancerGroupToSwitch(ls, serviceLBGroup); err != nil {
+			return err
+		}
+	}
+	for _, lr := range c.opts.ServiceRouters {
+		if err := c.ovnClient.AddLoadBalancerGroupToRouter(lr, serviceLBGroup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Controller) enqueueAllServices() {
+	svcs, err := c.serviceLister.List(labels.Everything())
+	if err != nil {
+		log.Printf("failed to list services: %v", err)
+		return
+	}
+	for _, svc := range svcs {
+		key, err := cache.MetaNamespaceKeyFunc(svc)
+		if err != nil {
+			continue
+		}
+		c.svcQueue.Add(key)
+	}
+}
+
+// enqueueStaleServices queues the services owning load balancers so that
+// services deleted while the controller was down get cleaned up.
+func (c *Controller) enqueueStaleServices() error {
+	lbs, err := c.ovnClient.ListLoadBalancers(ExternalIDOwnerType, ownerTypeService)
+	if err != nil {
+		return err
+	}
+	for _, lb := range lbs {
+		c.svcQueue.Add(lb.ExternalIDs[ExternalIDOwner])
+	}
+	return nil
+}
+
+// endpointSliceHandler queues the service an EndpointSlice belongs to.
+func (c *Controller) endpointSliceHandler() cache.ResourceEventHandlerFuncs {
+	enqueue := func(obj any) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		slice, ok := obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			return
+		}
+		if name := slice.Labels[discoveryv1.LabelServiceName]; name != "" {
+			c.svcQueue.Add(slice.Namespace + "/" + name)
+		}
+	}
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, obj any) { enqueue(obj) },
+		DeleteFunc: enqueue,
+	}
+}
+
+// syncService keeps one load balancer per protocol of a service in line with
+// its cluster IPs and ready endpoints.
+func (c *Controller) syncService(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	desired := map[string]*serviceLB{}
+	externalIP := ""
+	svc, err := c.serviceLister.Services(namespace).Get(name)
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		if desired, err = c.serviceLoadBalancers(key, svc); err != nil {
+			return err
+		}
+		if len(desired) > 0 && c.isPublicLoadBalancer(svc) {
+			if externalIP, err = c.setPublicLoadBalancers(svc, desired); err != nil {
+				return err
+			}
+		}
+	}
+
+	lbs, err := c.ovnClient.ListLoadBalancers(ExternalIDOwnerType, ownerTypeService)
+	if err != nil {
+		return err
+	}
+	released := externalIP != ""
+	for _, lb := range lbs {
+		if lb.ExternalIDs[ExternalIDOwner] != key || desired[lb.Name] != nil {
+			continue
+		}
+		// Release the external IP before its last load balancer goes, so
+		// that a failed release is retried.
+		if !released && strings.HasSuffix(lb.Name, "_public") {
+			if err := c.releasePublicPoolIP(namespace, name, "loadbalancer"); err != nil {
+				return err
+			}
+			released = true
+		}
+		if err := c.ovnClient.DeleteLoadBalancer(lb.Name); err != nil {
+			return err
+		}
+	}
+	for _, d := range desired {
+		if d.router != "" {
+			if err := c.ovnClient.EnsureLoadBalancer("", d.lb, d.healthChecks); err != nil {
+				return err
+			}
+			if err := c.ovnClient.AddLoadBalancerToRouter(d.router, d.lb.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.ovnClient.EnsureLoadBalancer(serviceLBGroup, d.lb, d.healthChecks); err != nil {
+			return err
+		}
+	}
+	if svc != nil && c.ownsLoadBalancerStatus(svc) {
+		return c.updateLoadBalancerStatus(svc, externalIP)
+	}
+	return nil
+}
+
+// serviceLB is a load balancer of a service with its backend health checks.
+// Load balancers with a router are applied to that router alone instead of
+// joining the service load balancer group.
+type serviceLB struct {
+	lb           *models.LoadBalancer
+	healthChecks []*models.LoadBalancerHealthCheck
+	router       string
+}
+
+// serviceLoadBalancers builds the load balancers of a service, keyed by name.
+// Headless and ExternalName services get none.
+func (c *Controller) serviceLoadBalancers(key string, svc *corev1.Service) (map[string]*serviceLB, error) {
+	lbs := map[string]*serviceLB{}
+	clusterIPs := svc.Spec.ClusterIPs
+	if len(clusterIPs) == 0 && svc.Spec.ClusterIP != "" {
+		clusterIPs = []string{svc.Spec.ClusterIP}
+	}
+	if svc.Spec.Type == corev1.ServiceTypeExternalName || len(clusterIPs) == 0 || clusterIPs[0] == corev1.ClusterIPNone {
+		return lbs, nil
+	}
+
+	endpointSlices, err := c.endpointSliceLister.EndpointSlices(svc.Namespace).List(labels.SelectorFromSet(labels.Set{
+		discoveryv1.LabelServiceName: svc.Name,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sp := range svc.Spec.Ports {
+		protocol := strings.ToLower(string(sp.Protocol))
+		if protocol == "" {
+			protocol = models.LoadBalancerProtocolTCP
+		}
+		lbName := "Service_" + key + "_" + protocol
+		d := lbs[lbName]
+		if d == nil {
+			d = &serviceLB{lb: &models.LoadBalancer{
+				Name:        lbName,
+				Protocol:    &protocol,
+				Vips:        map[string]string{},
+				Options:     serviceLBOptions(svc),
+				ExternalIDs: ownerIDs(ownerTypeService, key),
+			}}
+			lbs[lbName] = d
+		}
+		for _, vip := range clusterIPs {
+			backends := serviceBackends(endpointSlices, sp, addressType(vip))
+			d.lb.Vips[net.JoinHostPort(vip, strconv.Itoa(int(sp.Port)))] = strings.Join(backends, ",")
+		}
+	}
+	c.setNodePortLoadBalancers(key, svc, endpointSlices, lbs)
+	if err := c.setServiceHealthChecks(key, svc, lbs); err != nil {
+		return nil, err
+	}
+	return lbs, nil
+}
+
+// setServiceHealthChecks adds a health check per VIP to the load balancers
+// of a service annotated for it. OVN probes a backend from the configured
+// source IP through the logical port the backend sits behind, so backends
+// without a known port, IPv6 and SCTP VIPs are left unmonitored.
+func (c *Controller) setServiceHealthChecks(key string, svc *corev1.Service, lbs map[string]*serviceLB) error {
+	value, ok := svc.Annotations[v1alpha1.HealthCheckAnnotation]
+	if !ok || len(lbs) == 0 {
+		return nil
+	}
+	if c.opts.HealthCheckSourceIP == "" {
+		log.Printf("⚠️ Health checks requested by service %s but no health check source IP is configured", key)
+		return nil
+	}
+	hc := c.opts.HealthCheck
+	if err := json.Unmarshal([]byte(value), &hc); err != nil {
+		log.Printf("⚠️ Invalid %s annotation %q on service %s: %v", v1alpha1.HealthCheckAnnotation, value, key, err)
+		return nil
+	}
+	options := map[string]string{}
+	for name, v := range map[string]int{
+		"interval":      hc.Interval,
+		"timeout":       hc.Timeout,
+		"success_count": hc.SuccessCount,
+		"failure_count": hc.FailureCount,
+	} {
+		if v > 0 {
+			options[name] = strconv.Itoa(v)
+		}
+	}
+
+	ports, err := c.listPodPorts()
+	if err != nil {
+		return err
+	}
+	portByIP := map[string]string{}
+	for _, p := range ports {
+		if p.IP != "" {
+			portByIP[p.IP] = p.Name
+		}
+	}
+
+	for _, d := range lbs {
+		if *d.lb.Protocol == models.LoadBalancerProtocolSCTP || isTemplateLoadBalancer(d.lb) {
+			continue
+		}
+		mappings := map[string]string{}
+		for vip, backends := range d.lb.Vips {
+			host, _, err := net.SplitHostPort(vip)
+			if err != nil || addressType(host) != discoveryv1.AddressTypeIPv4 {
+				continue
+			}
+			d.healthChecks = append(d.healthChecks, &models.LoadBalancerHealthCheck{
+				Vip:         vip,
+				Options:     options,
+				ExternalIDs: ownerIDs(ownerTypeService, key),
+			})
+			for _, backend := range strings.Split(backends, ",") {
+				ip, _, err := net.SplitHostPort(backend)
+				if err != nil {
+					continue
+				}
+				if lsp, ok := portByIP[ip]; ok {
+					mappings[ip] = lsp + ":" + c.opts.HealthCheckSourceIP
+				}
+			}
+		}
+		d.lb.IPPortMappings = mappings
+	}
+	return nil
+}
+
+// serviceBackends returns the sorted ip:port backends of a service port
+// taken from the ready endpoints of the given address family.
+func serviceBackends(endpointSlices []*discoveryv1.EndpointSlice, sp corev1.ServicePort, family discoveryv1.AddressType) []string {
+	seen := map[string]bool{}
+	backends := []string{}
+	for _, slice := range endpointSlices {
+		if slice.AddressType != family {
+			continue
+		}
+		var port int32
+		for _, p := range slice.Ports {
+			if p.Port == nil || (p.Protocol != nil && *p.Protocol != sp.Protocol) {
+				continue
+			}
+			if (p.Name == nil && sp.Name == "") || (p.Name != nil && *p.Name == sp.Name) {
+				port = *p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			for _, addr := range ep.Addresses {
+				backend := net.JoinHostPort(addr, strconv.Itoa(int(port)))
+				if !seen[backend] {
+					seen[backend] = true
+					backends = append(backends, backend)
+				}
+			}
+		}
+	}
+	sort.Strings(backends)
+	return backends
+}
+
+func addressType(ip string) discoveryv1.AddressType {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return discoveryv1.AddressTypeIPv6
+	}
+	return discoveryv1.AddressTypeIPv4
+}
+
+// serviceLBOptions rejects connections to VIPs without backends, like
+// kube-proxy, and maps ClientIP session affinity onto OVN's affinity timeout.
+func serviceLBOptions(svc *corev1.Service) map[string]string {
+	options := map[string]string{"reject": "true"}
+	if svc.Spec.SessionAffinity == corev1.ServiceAffinityClientIP {
+		timeout := int32(corev1.DefaultClientIPServiceAffinitySeconds)
+		if cfg := svc.Spec.SessionAffinityConfig; cfg != nil && cfg.ClientIP != nil && cfg.ClientIP.TimeoutSeconds != nil {
+			timeout = *cfg.ClientIP.TimeoutSeconds
+		}
+		options["affinity_timeout"] = strconv.Itoa(int(timeout))
+	}
+	return options
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/net_utils"
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// isPublicLoadBalancer reports whether the controller exposes the service on
+// the gateway router. Services claimed by another load balancer class are
+// left to their implementation.
+func (c *Controller) isPublicLoadBalancer(svc *corev1.Service) bool {
+	return c.opts.GatewayRouter != "" && svc.Spec.Type == corev1.ServiceTypeLoadBalancer &&
+		svc.Spec.LoadBalancerClass == nil
+}
+
+// serviceExternalIP returns the public address of a LoadBalancer service:
+// the one already published in its status, or a new one from the IPAM pool.
+func (c *Controller) serviceExternalIP(svc *corev1.Service) (string, error) {
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if net.ParseIP(ingress.IP) != nil {
+			return ingress.IP, nil
+		}
+	}
+
+	return c.publicPoolIP(svc.Namespace, svc.Name, "loadbalancer", svc.Annotations)
+}
+
+// publicPoolIP returns the address IPAM assigns to the object from the
+// public pool of its PublicIPPoolAnnotation, or the controller default.
+func (c *Controller) publicPoolIP(namespace, name, iface string, annotations map[string]string) (string, error) {
+	pool := c.opts.PublicIPPool
+	if value := annotations[v1alpha1.PublicIPPoolAnnotation]; value != "" {
+		pool = value
+	}
+	resp, err := net_utils.RequestAssignmentFromIPAM(net_utils.IpAssignmentRequestBody{
+		Namespace:          namespace,
+		Name:               name,
+		ContainerInterface: iface,
+		IpFamily:           "IPv4",
+		PublicIpPoolName:   pool,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to request external ip from ipam: %v", err)
+	}
+	if resp.PublicIpPoolName == "" || (pool != "" && resp.PublicIpPoolName != pool) {
+		return "", fmt.Errorf("ipam returned %q from pool %q instead of a public pool address", resp.Address, resp.PublicIpPoolName)
+	}
+	ip := strings.Split(resp.Address, "/")[0]
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("ipam returned invalid address %q", resp.Address)
+	}
+	log.Printf("✅ Assigned external ip %s from pool %s to %s %s/%s", ip, resp.PublicIpPoolName, iface, namespace, name)
+	return ip, nil
+}
+
+// releasePublicPoolIP hands the public pool address of the namespace/name
+// interface back to IPAM, which keeps assignments until they are released.
+func (c *Controller) releasePublicPoolIP(namespace, name, iface string) error {
+	err := net_utils.ReleaseAssignmentFromIPAM(net_utils.IpAssignmentRequestBody{
+		Namespace:          namespace,
+		Name:               name,
+		ContainerInterface: iface,
+		IpFamily:           "IPv4",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release external ip from ipam: %v", err)
+	}
+	log.Printf("🧹 Released external ip of %s %s/%s", iface, namespace, name)
+	return nil
+}
+
+// setPublicLoadBalancers adds, next to each cluster load balancer of the
+// service, one on the gateway router serving the same backends on the
+// external IP, with the same health checks so that external clients are
+// not sent to offline backends either.
+func (c *Controller) setPublicLoadBalancers(svc *corev1.Service, lbs map[string]*serviceLB) (string, error) {
+	externalIP, err := c.serviceExternalIP(svc)
+	if err != nil {
+		return "", err
+	}
+	public := map[string]*serviceLB{}
+	for _, d := range lbs {
+		// NodePorts stay on the node addresses.
+		if isTemplateLoadBalancer(d.lb) {
+			continue
+		}
+		lb := &models.LoadBalancer{
+			Name:           d.lb.Name + "_public",
+			Protocol:       d.lb.Protocol,
+			Vips:           map[string]string{},
+			IPPortMappings: d.lb.IPPortMappings,
+			Options:        d.lb.Options,
+			ExternalIDs:    d.lb.ExternalIDs,
+		}
+		healthChecks := map[string]*models.LoadBalancerHealthCheck{}
+		for _, hc := range d.healthChecks {
+			healthChecks[hc.Vip] = hc
+		}
+		var publicHealthChecks []*models.LoadBalancerHealthCheck
+		for vip, backends := range d.lb.Vips {
+			host, port, err := net.SplitHostPort(vip)
+			if err != nil || addressType(host) != addressType(externalIP) {
+				continue
+			}
+			publicVip := net.JoinHostPort(externalIP, port)
+			lb.Vips[publicVip] = backends
+			if hc, ok := healthChecks[vip]; ok {
+				publicHealthChecks = append(publicHealthChecks, &models.LoadBalancerHealthCheck{
+					Vip:         publicVip,
+					Options:     hc.Options,
+					ExternalIDs: hc.ExternalIDs,
+				})
+			}
+		}
+		public[lb.Name] = &serviceLB{lb: lb, healthChecks: publicHealthChecks, router: c.opts.GatewayRouter}
+	}
+	for name, d := range public {
+		lbs[name] = d
+	}
+	return externalIP, nil
+}
+
+// updateLoadBalancerStatus publishes the external IP of the service, or
+// clears the published one when externalIP is empty.
+func (c *Controller) updateLoadBalancerStatus(svc *corev1.Service, externalIP string) error {
+	var ingress []corev1.LoadBalancerIngress
+	if externalIP != "" {
+		ingress = []corev1.LoadBalancerIngress{{IP: externalIP}}
+	}
+	if slices.EqualFunc(svc.Status.LoadBalancer.Ingress, ingress, func(a, b corev1.LoadBalancerIngress) bool {
+		return a.IP == b.IP && a.Hostname == b.Hostname
+	}) {
+		return nil
+	}
+	svc = svc.DeepCopy()
+	svc.Status.LoadBalancer.Ingress = ingress
+	_, err := c.kubeClient.CoreV1().Services(svc.Namespace).UpdateStatus(context.Background(), svc, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to update status of service %s/%s: %v", svc.Namespace, svc.Name, err)
+	}
+	if externalIP == "" {
+		log.Printf("🧹 Cleared external ip of service %s/%s", svc.Namespace, svc.Name)
+		return nil
+	}
+	log.Printf("✅ Published external ip %s of service %s/%s", externalIP, svc.Namespace, svc.Name)
+	return nil
+}
+
+// ownsLoadBalancerStatus reports whether the load balancer status of the
+// service is the controller's to publish or clear: it is a public load
+// balancer of the controller, or no longer a LoadBalancer at all.
+func (c *Controller) ownsLoadBalancerStatus(svc *corev1.Service) bool {
+	return svc.Spec.Type != corev1.ServiceTypeLoadBalancer || c.isPublicLoadBalancer(svc)
+}
@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/net_utils"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const ownerTypeSubPort = "sub-port"
+
+func (c *Controller) enqueueAllSubPorts() {
+	subPorts, err := c.subPortLister.List(labels.Everything())
+	if err != nil {
+		log.Printf("failed to list sub-ports: %v", err)
+		return
+	}
+	for _, obj := range subPorts {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			continue
+		}
+		c.subPortQueue.Add(key)
+	}
+}
+
+// enqueueStaleSubPorts queues the owners of child ports so that sub-ports
+// deleted while the controller was down get cleaned up.
+func (c *Controller) enqueueStaleSubPorts() error {
+	lsps, err := c.ovnClient.ListChildLogicalPorts()
+	if err != nil {
+		return err
+	}
+	for _, lsp := range lsps {
+		if lsp.ExternalIDs[ExternalIDOwnerType] == ownerTypeSubPort {
+			c.subPortQueue.Add(lsp.ExternalIDs[ExternalIDOwner])
+		}
+	}
+	return nil
+}
+
+// syncSubPort keeps the child port of a SubPort nested in the port of its VM
+// interface. The child shares the workload ids of its parent, so it joins
+// the namespace and the policies selecting the VM pod.
+func (c *Controller) syncSubPort(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	lspName := hashedName("subport", key)
+
+	obj, err := c.subPortLister.ByNamespace(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		// Released before the port goes, so that a failed release is retried
+		// through the stale child port.
+		if err := releaseSubPortAddress(namespace, name); err != nil {
+			return err
+		}
+		return c.ovnClient.DeleteLogicalPort(c.opts.LogicalSwitch, lspName)
+	}
+	if err != nil {
+		return err
+	}
+	sp := &v1alpha1.SubPort{}
+	if err := v1alpha1.FromUnstructured(obj, sp); err != nil {
+		return fmt.Errorf("failed to decode sub-port %s: %v", key, err)
+	}
+
+	if sp.Spec.VLAN < 1 || sp.Spec.VLAN > 4095 {
+		log.Printf("⚠️ Invalid sub-port %s: vlan %d out of range", key, sp.Spec.VLAN)
+		return c.ovnClient.DeleteLogicalPort(c.opts.LogicalSwitch, lspName)
+	}
+	mac, ip, err := subPortAddress(namespace, name, sp.Spec)
+	if err != nil {
+		return err
+	}
+	if mac == "" {
+		log.Printf("⚠️ Invalid sub-port %s: addresses differ from the IPAM assignment", key)
+		return c.ovnClient.DeleteLogicalPort(c.opts.LogicalSwitch, lspName)
+	}
+	for _, address := range []string{mac, ip} {
+		holders, err := c.ovnClient.ListLogicalPortsWithAddress(address)
+		if err != nil {
+			return err
+		}
+		for _, lsp := range holders {
+			if lsp.Name != lspName {
+				log.Printf("⚠️ Address %s of sub-port %s is held by port %s", address, key, lsp.Name)
+				return c.ovnClient.DeleteLogicalPort(c.opts.LogicalSwitch, lspName)
+			}
+		}
+	}
+	ifName := sp.Spec.Interface
+	if ifName == "" {
+		ifName = "eth0"
+	}
+	parent, err := c.ovnClient.FindPodLogicalPort(namespace, sp.Spec.VMName, ifName)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		// The VM is not running; its pod coming up resyncs the sub-port.
+		return c.ovnClient.DeleteLogicalPort(c.opts.LogicalSwitch, lspName)
+	}
+
+	ids := ownerIDs(ownerTypeSubPort, key)
+	for _, id := range []string{ovnnb.ExternalIDNamespace, ovnnb.ExternalIDPod, ovnnb.ExternalIDVM} {
+		ids[id] = parent.ExternalIDs[id]
+	}
+	ids[ovnnb.ExternalIDInterface] = fmt.Sprintf("%s.%d", ifName, sp.Spec.VLAN)
+	ids[ovnnb.ExternalIDIP] = ip
+	return c.ovnClient.EnsureChildLogicalPort(c.opts.LogicalSwitch, lspName, parent.Name, sp.Spec.VLAN, []string{mac + " " + ip}, ids)
+}
+
+// subPortAddress returns the MAC and IP IPAM assigns to the sub-port. The
+// addresses of the spec, when given, must be the assigned ones; otherwise
+// the returned MAC is empty.
+func subPortAddress(namespace, name string, spec v1alpha1.SubPortSpec) (string, string, error) {
+	resp, err := net_utils.RequestAssignmentFromIPAM(net_utils.IpAssignmentRequestBody{
+		Namespace:          namespace,
+		Name:               name,
+		ContainerInterface: "subport",
+		IpFamily:           "IPv4",
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to request sub-port address from ipam: %v", err)
+	}
+	mac, err := net.ParseMAC(resp.MacAddress)
+	if err != nil {
+		return "", "", fmt.Errorf("ipam returned invalid mac address %q", resp.MacAddress)
+	}
+	ip := strings.Split(resp.Address, "/")[0]
+	if net.ParseIP(ip) == nil {
+		return "", "", fmt.Errorf("ipam returned invalid address %q", resp.Address)
+	}
+
+	if spec.MACAddress != "" {
+		if requested, err := net.ParseMAC(spec.MACAddress); err != nil || requested.String() != mac.String() {
+			return "", "", nil
+		}
+	}
+	if len(spec.IPs) > 1 || (len(spec.IPs) == 1 && spec.IPs[0] != ip) {
+		return "", "", nil
+	}
+	return mac.String(), ip, nil
+}
+
+// releaseSubPortAddress hands the addresses of a deleted sub-port back to
+// IPAM.
+func releaseSubPortAddress(namespace, name string) error {
+	err := net_utils.ReleaseAssignmentFromIPAM(net_utils.IpAssignmentRequestBody{
+		Namespace:          namespace,
+		Name:               name,
+		ContainerInterface: "subport",
+		IpFamily:           "IPv4",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release sub-port address from ipam: %v", err)
+	}
+	log.Printf("🧹 Released address of sub-port %s/%s", namespace, name)
+	return nil
+}
@@ -0,0 +1,315 @@
+// Package daemon runs on every node and keeps the OVS and OVN state of the
+// local pod interfaces in line with their pods after the CNI ADD.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/net_utils"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb"
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/ovs"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Options tunes the daemon.
+type Options struct {
+	// NodeName is the node whose pods the daemon manages.
+	NodeName string
+	// LogicalSwitch holds the logical ports of the pods.
+	LogicalSwitch string
+	// Bridge is the integration bridge the pod interfaces are plugged into.
+	Bridge string
+	// FlowExport is the flow export configuration of the bridge, left
+	// untouched when nil.
+	FlowExport *ovs.FlowExport
+	// ProviderNetworks are the physical networks the node bridges provider
+	// network switches to.
+	ProviderNetworks []ProviderNetwork
+}
+
+// ProviderNetwork maps an ovn-bridge-mappings network to a bridge of the
+// node and the uplink NIC plugged into it.
+type ProviderNetwork struct {
+	Name   string
+	Bridge string
+	Uplink string
+}
+
+// Daemon reconciles the pods running on its node.
+type Daemon struct {
+	opts Options
+
+	ovnClient *ovnnb.Client
+	ovsClient *ovs.Client
+
+	informerFactory  informers.SharedInformerFactory
+	namespaceFactory informers.SharedInformerFactory
+	podLister        corelisters.PodLister
+	namespaceLister  corelisters.NamespaceLister
+	synced           []cache.InformerSynced
+
+	podQueue workqueue.TypedRateLimitingInterface[string]
+}
+
+func NewDaemon(kubeClient kubernetes.Interface, ovnClient *ovnnb.Client, ovsClient *ovs.Client, opts Options) (*Daemon, error) {
+	if opts.NodeName == "" {
+		return nil, fmt.Errorf("node name is required")
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(kubeClient, 0,
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", opts.NodeName).String()
+		}))
+	podInformer := factory.Core().V1().Pods()
+	// Namespaces are not node scoped, so they get their own factory.
+	namespaceFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	namespaceInformer := namespaceFactory.Core().V1().Namespaces()
+
+	d := &Daemon{
+		opts:             opts,
+		ovnClient:        ovnClient,
+		ovsClient:        ovsClient,
+		informerFactory:  factory,
+		namespaceFactory: namespaceFactory,
+		podLister:        podInformer.Lister(),
+		namespaceLister:  namespaceInformer.Lister(),
+		synced: []cache.InformerSynced{
+			podInformer.Informer().HasSynced,
+			namespaceInformer.Informer().HasSynced,
+		},
+		podQueue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "pod"},
+		),
+	}
+
+	enqueue := func(obj any) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			log.Printf("failed to get key for object: %v", err)
+			return
+		}
+		d.podQueue.Add(key)
+	}
+	if _, err := podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, obj any) { enqueue(obj) },
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := namespaceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj any) { d.enqueueNamespacePods(obj) },
+	}); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// enqueueNamespacePods resyncs the local pods of a namespace whose
+// annotations may have changed.
+func (d *Daemon) enqueueNamespacePods(obj any) {
+	ns, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return
+	}
+	pods, err := d.podLister.Pods(ns.Name).List(labels.Everything())
+	if err != nil {
+		log.Printf("failed to list pods of namespace %s: %v", ns.Name, err)
+		return
+	}
+	for _, pod := range pods {
+		d.podQueue.Add(ns.Name + "/" + pod.Name)
+	}
+}
+
+// Run starts the informers and workers and blocks until ctx is cancelled.
+func (d *Daemon) Run(ctx context.Context, workers int) error {
+	defer d.podQueue.ShutDown()
+
+	for _, pn := range d.opts.ProviderNetworks {
+		if err := d.ovsClient.EnsureBridge(pn.Bridge, pn.Uplink); err != nil {
+			return err
+		}
+		if err := d.ovsClient.SetBridgeMapping(pn.Name, pn.Bridge); err != nil {
+			return err
+		}
+	}
+	if d.opts.FlowExport != nil {
+		if err := d.ovsClient.SetBridgeFlowExport(d.opts.Bridge, *d.opts.FlowExport); err != nil {
+			return err
+		}
+	}
+
+	d.informerFactory.Start(ctx.Done())
+	d.namespaceFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), d.synced...) {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+	for range workers {
+		go wait.UntilWithContext(ctx, func(ctx context.Context) {
+			for d.processNextItem() {
+			}
+		}, time.Second)
+	}
+
+	log.Printf("✅ Daemon started on node %s with %d workers", d.opts.NodeName, workers)
+	<-ctx.Done()
+	return nil
+}
+
+func (d *Daemon) processNextItem() bool {
+	key, shutdown := d.podQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer d.podQueue.Done(key)
+
+	if err := d.syncPod(key); err != nil {
+		log.Printf("error syncing %s: %v", key, err)
+		d.podQueue.AddRateLimited(key)
+		return true
+	}
+	d.podQueue.Forget(key)
+	return true
+}
+
+// syncPod applies the annotations of a local pod to its interface. Pods
+// without a port created by the CNI are ignored; ports of deleted pods are
+// cleaned up by the CNI DEL.
+func (d *Daemon) syncPod(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	pod, err := d.podLister.Pods(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lsps, err := d.ovnClient.ListPodLogicalPorts()
+	if err != nil {
+		return err
+	}
+	for _, lsp := range lsps {
+		// Child ports of sub-ports have no OVS interface of their own.
+		if lsp.ParentName != nil {
+			continue
+		}
+		if lsp.ExternalIDs[ovnnb.ExternalIDNamespace] != namespace || lsp.ExternalIDs[ovnnb.ExternalIDPod] != name {
+			continue
+		}
+		if err := d.syncWorkloadIDs(lsp.Name, lsp.ExternalIDs); err != nil {
+			return err
+		}
+		if err := d.syncBandwidth(lsp.Name, lsp.ExternalIDs, pod.Annotations); err != nil {
+			return err
+		}
+		if err := d.syncQueue(lsp.Name, pod.Annotations); err != nil {
+			return err
+		}
+		if err := d.syncConntrack(lsp.Name, pod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncWorkloadIDs copies the workload identity of the logical port to the
+// OVS interface, so flow collectors can map the exported flows back to the
+// namespace and VM.
+func (d *Daemon) syncWorkloadIDs(port string, lspIDs map[string]string) error {
+	ids := map[string]string{}
+	for _, key := range []string{ovnnb.ExternalIDNamespace, ovnnb.ExternalIDPod, ovnnb.ExternalIDVM} {
+		if v, ok := lspIDs[key]; ok {
+			ids[key] = v
+		}
+	}
+	return d.ovsClient.MergeInterfaceExternalIDs(port, ids)
+}
+
+// syncBandwidth enforces the bandwidth limits of the port: the egress limit
+// polices what OVS receives from the host veth, the ingress limit is a QoS
+// rule on the traffic sent to the logical port. Limits the bandwidth
+// capability handed over at ADD are kept, the annotations apply otherwise.
+func (d *Daemon) syncBandwidth(port string, lspIDs, annotations map[string]string) error {
+	bw := net_utils.Bandwidth{}
+	if value, ok := lspIDs[ovnnb.ExternalIDBandwidth]; ok {
+		if err := json.Unmarshal([]byte(value), &bw); err != nil {
+			log.Printf("⚠️ Invalid %s of port %s %q: %v", ovnnb.ExternalIDBandwidth, port, value, err)
+			return nil
+		}
+	} else {
+		var err error
+		if bw, err = net_utils.BandwidthFromAnnotations(annotations); err != nil {
+			log.Printf("⚠️ %v", err)
+			return nil
+		}
+	}
+	if err := d.ovsClient.SetInterfaceIngressPolicing(port, net_utils.Kilo(bw.EgressRate), net_utils.Kilo(bw.EgressBurst)); err != nil {
+		return err
+	}
+	return d.ovnClient.SetLogicalPortBandwidth(d.opts.LogicalSwitch, port, net_utils.Kilo(bw.IngressRate), net_utils.Kilo(bw.IngressBurst))
+}
+
+// syncQueue shapes the traffic to the pod with the linux-htb queue of its
+// annotations.
+func (d *Daemon) syncQueue(port string, annotations map[string]string) error {
+	if vhostUser, err := d.ovsClient.IsVhostUserPort(port); err != nil {
+		return err
+	} else if vhostUser {
+		return nil
+	}
+	q, err := net_utils.QueueFromAnnotations(annotations)
+	if err != nil {
+		log.Printf("⚠️ %v", err)
+		return nil
+	}
+	return d.ovsClient.SetPortQueue(port, q.MinRate, q.MaxRate, q.Priority)
+}
+
+// syncConntrack applies the conntrack limit and timeouts of the pod, or of
+// its namespace, to the conntrack zone ovn-controller gave its port.
+func (d *Daemon) syncConntrack(port string, pod *corev1.Pod) error {
+	var nsAnnotations map[string]string
+	ns, err := d.namespaceLister.Get(pod.Namespace)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if ns != nil {
+		nsAnnotations = ns.Annotations
+	}
+	ct, err := net_utils.ConntrackFromAnnotations(nsAnnotations, pod.Annotations)
+	if err != nil {
+		log.Printf("⚠️ %v", err)
+		return nil
+	}
+
+	zone, ok, err := d.ovsClient.PortConntrackZone(d.opts.Bridge, port)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if ct.Limit == 0 && len(ct.Timeouts) == 0 {
+			return nil
+		}
+		// Retried until ovn-controller claims the port.
+		return fmt.Errorf("no conntrack zone assigned to port %s yet", port)
+	}
+	return d.ovsClient.SetConntrackZone(d.opts.Bridge, port, zone, ct.Limit, ct.Timeouts)
+}
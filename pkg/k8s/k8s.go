@@ -4,12 +4,14 @@ import (
 	"os"
 	"path/filepath"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 var client *kubernetes.Clientset
+var dynamicClient *dynamic.DynamicClient
 
 func CreateClient() (*kubernetes.Clientset, error) {
 	// singleton
@@ -29,10 +31,33 @@ func CreateClient() (*kubernetes.Clientset, error) {
 	return client, nil
 }
 
+// CreateDynamicClient returns a client for the custom resources of the plugin.
+func CreateDynamicClient() (*dynamic.DynamicClient, error) {
+	// singleton
+	if dynamicClient != nil {
+		return dynamicClient, nil
+	}
+
+	config, err := createConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err = dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return dynamicClient, nil
+}
+
 func createConfig() (*rest.Config, error) {
 	configFile := filepath.Join("/etc/rancher/k3s", "k3s.yaml")
 	_, err := os.Stat(configFile)
 	if err != nil {
+		// no node kubeconfig, try the service account when running in a pod
+		if config, inClusterErr := rest.InClusterConfig(); inClusterErr == nil {
+			return config, nil
+		}
 		return nil, err
 	}
 	return clientcmd.BuildConfigFromFlags("", configFile)
@@ -0,0 +1,90 @@
+package net_utils
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Pod annotations understood by the CNI bandwidth capability.
+const (
+	IngressBandwidthAnnotation = "kubernetes.io/ingress-bandwidth"
+	EgressBandwidthAnnotation  = "kubernetes.io/egress-bandwidth"
+)
+
+// Bandwidth holds the limits of a pod interface in bits per second, with
+// bursts in bits, as the CNI bandwidth capability does. Ingress is the
+// traffic sent to the pod, egress the traffic it sends. Zero means unlimited.
+type Bandwidth struct {
+	IngressRate  int `json:"ingressRate,omitempty"`
+	IngressBurst int `json:"ingressBurst,omitempty"`
+	EgressRate   int `json:"egressRate,omitempty"`
+	EgressBurst  int `json:"egressBurst,omitempty"`
+}
+
+// BandwidthFromAnnotations reads the limits of the kubernetes.io bandwidth
+// annotations, e.g. "10M".
+func BandwidthFromAnnotations(annotations map[string]string) (Bandwidth, error) {
+	bw := Bandwidth{}
+	for annotation, rate := range map[string]*int{
+		IngressBandwidthAnnotation: &bw.IngressRate,
+		EgressBandwidthAnnotation:  &bw.EgressRate,
+	} {
+		value, ok := annotations[annotation]
+		if !ok {
+			continue
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return Bandwidth{}, fmt.Errorf("invalid %s annotation %q: %v", annotation, value, err)
+		}
+		*rate = int(q.Value())
+	}
+	return bw, nil
+}
+
+// Kilo converts a rate in bits per second, or a burst in bits, to the kbps
+// and kb units of OVS and OVN.
+func Kilo(bits int) int {
+	if bits > 0 && bits < 1000 {
+		return 1
+	}
+	return bits / 1000
+}
+
+// Queue holds the linux-htb queue of a pod interface, rates in bits per
+// second. The zero Queue means no shaping.
+type Queue struct {
+	MinRate  int
+	MaxRate  int
+	Priority int
+}
+
+// QueueFromAnnotations reads the queue annotations of a pod.
+func QueueFromAnnotations(annotations map[string]string) (Queue, error) {
+	q := Queue{}
+	for annotation, rate := range map[string]*int{
+		v1alpha1.QueueMinRateAnnotation: &q.MinRate,
+		v1alpha1.QueueMaxRateAnnotation: &q.MaxRate,
+	} {
+		value, ok := annotations[annotation]
+		if !ok {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return Queue{}, fmt.Errorf("invalid %s annotation %q: %v", annotation, value, err)
+		}
+		*rate = int(quantity.Value())
+	}
+	if value, ok := annotations[v1alpha1.QueuePriorityAnnotation]; ok {
+		priority, err := strconv.Atoi(value)
+		if err != nil || priority < 0 {
+			return Queue{}, fmt.Errorf("invalid %s annotation %q", v1alpha1.QueuePriorityAnnotation, value)
+		}
+		q.Priority = priority
+	}
+	return q, nil
+}
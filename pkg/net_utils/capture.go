@@ -0,0 +1,78 @@
+package net_utils
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const captureSnaplen = 65535
+
+// CapturePackets writes the frames received on ifName to w in pcap format
+// until ctx is done or count frames were written, when count is positive.
+func CapturePackets(ctx context.Context, ifName string, w io.Writer, count int) (int, error) {
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find interface %s: %w", ifName, err)
+	}
+	protocol := htons(unix.ETH_P_ALL)
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(protocol))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open packet socket: %w", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: protocol, Ifindex: iface.Index}); err != nil {
+		return 0, fmt.Errorf("failed to bind packet socket to %s: %w", ifName, err)
+	}
+	// Wake up regularly to notice ctx being done.
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &unix.Timeval{Sec: 1}); err != nil {
+		return 0, fmt.Errorf("failed to set packet socket timeout: %w", err)
+	}
+
+	// pcap global header: magic, version 2.4, UTC, snaplen, Ethernet
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], captureSnaplen)
+	binary.LittleEndian.PutUint32(header[20:], 1)
+	if _, err := w.Write(header); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, captureSnaplen)
+	record := make([]byte, 16)
+	written := 0
+	for ctx.Err() == nil && (count <= 0 || written < count) {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return written, fmt.Errorf("failed to read packet: %w", err)
+		}
+		now := time.Now()
+		binary.LittleEndian.PutUint32(record[0:], uint32(now.Unix()))
+		binary.LittleEndian.PutUint32(record[4:], uint32(now.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(record[8:], uint32(n))
+		binary.LittleEndian.PutUint32(record[12:], uint32(n))
+		if _, err := w.Write(record); err != nil {
+			return written, err
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
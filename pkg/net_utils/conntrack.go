@@ -0,0 +1,54 @@
+package net_utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/cybercoder/ik8s-ovn-cni/pkg/apis/v1alpha1"
+)
+
+// conntrackTimeouts are the timeouts a CT_Timeout_Policy accepts.
+var conntrackTimeouts = map[string]bool{
+	"tcp_syn_sent": true, "tcp_syn_recv": true, "tcp_established": true,
+	"tcp_fin_wait": true, "tcp_close_wait": true, "tcp_last_ack": true,
+	"tcp_time_wait": true, "tcp_close": true, "tcp_syn_sent2": true,
+	"tcp_retransmit": true, "tcp_unack": true,
+	"udp_first": true, "udp_single": true, "udp_multiple": true,
+	"icmp_first": true, "icmp_reply": true,
+}
+
+// Conntrack holds the conntrack limit and timeouts, in seconds, of the zone
+// of a pod interface. The zero Conntrack leaves the zone alone.
+type Conntrack struct {
+	Limit    int
+	Timeouts map[string]int
+}
+
+// ConntrackFromAnnotations reads the conntrack annotations of a namespace and
+// of a pod, the pod ones taking precedence.
+func ConntrackFromAnnotations(namespaceAnnotations, podAnnotations map[string]string) (Conntrack, error) {
+	ct := Conntrack{}
+	for _, annotations := range []map[string]string{namespaceAnnotations, podAnnotations} {
+		if value, ok := annotations[v1alpha1.ConntrackLimitAnnotation]; ok {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				return Conntrack{}, fmt.Errorf("invalid %s annotation %q", v1alpha1.ConntrackLimitAnnotation, value)
+			}
+			ct.Limit = limit
+		}
+		if value, ok := annotations[v1alpha1.ConntrackTimeoutsAnnotation]; ok {
+			timeouts := map[string]int{}
+			if err := json.Unmarshal([]byte(value), &timeouts); err != nil {
+				return Conntrack{}, fmt.Errorf("invalid %s annotation %q: %v", v1alpha1.ConntrackTimeoutsAnnotation, value, err)
+			}
+			for name, seconds := range timeouts {
+				if !conntrackTimeouts[name] || seconds < 0 {
+					return Conntrack{}, fmt.Errorf("invalid %s timeout %s=%d", v1alpha1.ConntrackTimeoutsAnnotation, name, seconds)
+				}
+			}
+			ct.Timeouts = timeouts
+		}
+	}
+	return ct, nil
+}
@@ -0,0 +1,96 @@
+package net_utils
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ResolveIPv4WithTTL looks up the A records of name through the first
+// nameserver of /etc/resolv.conf and returns them with the lowest record TTL,
+// which the standard library resolver does not expose.
+func ResolveIPv4WithTTL(name string) ([]string, time.Duration, error) {
+	server, err := nameserver("/etc/resolv.conf")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid dns name %q: %w", name, err)
+	}
+	id := uint16(rand.IntN(1 << 16))
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to pack dns query: %w", err)
+	}
+
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(server, "53"), 5*time.Second)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to reach nameserver %s: %w", server, err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return nil, 0, err
+	}
+	if _, err := conn.Write(packed); err != nil {
+		return nil, 0, fmt.Errorf("failed to send dns query: %w", err)
+	}
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read dns response: %w", err)
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf[:n]); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse dns response: %w", err)
+	}
+	if resp.ID != id {
+		return nil, 0, fmt.Errorf("dns response id mismatch")
+	}
+	if resp.RCode != dnsmessage.RCodeSuccess {
+		return nil, 0, fmt.Errorf("dns lookup of %s failed: %s", name, resp.RCode)
+	}
+
+	ips := []string{}
+	var ttl uint32
+	for _, answer := range resp.Answers {
+		a, ok := answer.Body.(*dnsmessage.AResource)
+		if !ok {
+			continue
+		}
+		ips = append(ips, net.IP(a.A[:]).String())
+		if ttl == 0 || answer.Header.TTL < ttl {
+			ttl = answer.Header.TTL
+		}
+	}
+	return ips, time.Duration(ttl) * time.Second, nil
+}
+
+func nameserver(resolvConf string) (string, error) {
+	f, err := os.Open(resolvConf)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("no nameserver in %s", resolvConf)
+}
@@ -147,3 +147,20 @@ func RequestAssignmentFromIPAM(reqBody IpAssignmentRequestBody) (*IpAssignmentRe
 	}
 	return result, nil
 }
+
+// ReleaseAssignmentFromIPAM returns the address assigned to the
+// namespace/name/interface of the request to its pool. Releasing an
+// assignment that no longer exists is not an error.
+func ReleaseAssignmentFromIPAM(reqBody IpAssignmentRequestBody) error {
+	jsonData, _ := json.Marshal(reqBody)
+	resp, err := http.Post("http://172.16.35.20:8000/apis/ovn.ik8s.ir/v1alpha1/releaseip", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ipam refused to release %s/%s %s: %s %s", reqBody.Namespace, reqBody.Name, reqBody.ContainerInterface, resp.Status, respBody)
+	}
+	return nil
+}
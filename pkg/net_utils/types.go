@@ -5,6 +5,8 @@ type IpAssignmentRequestBody struct {
 	Name               string `json:"name"`
 	ContainerInterface string `json:"containerInterface"`
 	IpFamily           string `json:"ipFamily"`
+	// PublicIpPoolName asks for an address of the given public pool.
+	PublicIpPoolName string `json:"publicIpPoolName,omitempty"`
 }
 
 type IpAssignmentResponseBody struct {
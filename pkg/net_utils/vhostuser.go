@@ -0,0 +1,45 @@
+package net_utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultVhostUserSocketDir is the host directory holding the vhost-user
+// sockets, shared with the virt-launcher pods.
+const DefaultVhostUserSocketDir = "/var/run/ovn-cni/vhostuser"
+
+// DefaultVhostUserSocketGID is the group QEMU runs as in virt-launcher.
+const DefaultVhostUserSocketGID = 107
+
+// VhostUserSocketDir returns, creating it, the socket directory of a pod.
+// QEMU runs unprivileged in virt-launcher and creates the socket, so the
+// directory is shared with its group only.
+func VhostUserSocketDir(baseDir, podUID string, gid int) (string, error) {
+	if baseDir == "" {
+		baseDir = DefaultVhostUserSocketDir
+	}
+	dir := filepath.Join(baseDir, podUID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create vhost-user socket dir %s: %w", dir, err)
+	}
+	if err := os.Chown(dir, -1, gid); err != nil {
+		return "", fmt.Errorf("failed to give vhost-user socket dir %s to group %d: %w", dir, gid, err)
+	}
+	if err := os.Chmod(dir, 0o770); err != nil {
+		return "", fmt.Errorf("failed to open up vhost-user socket dir %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// RemoveVhostUserSocketDir removes the socket directory of a pod.
+func RemoveVhostUserSocketDir(baseDir, podUID string) error {
+	if baseDir == "" {
+		baseDir = DefaultVhostUserSocketDir
+	}
+	if podUID == "" {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(baseDir, podUID))
+}
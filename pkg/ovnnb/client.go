@@ -2,10 +2,13 @@ package ovnnb
 
 import (
 	"context"
+	"fmt"
+	"log"
 
 	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
 	"github.com/ovn-kubernetes/libovsdb/client"
 	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
 )
 
 type Client struct {
@@ -15,8 +18,27 @@ type Client struct {
 func CreateOvnNbClient(nbEndpoint string) (*Client, error) {
 	// Define database model
 	dbModel, err := model.NewClientDBModel("OVN_Northbound", map[string]model.Model{
-		"Logical_Switch":      &models.LogicalSwitch{},
-		"Logical_Switch_Port": &models.LogicalSwitchPort{},
+		"Logical_Switch":              &models.LogicalSwitch{},
+		"Logical_Switch_Port":         &models.LogicalSwitchPort{},
+		"DNS":                         &models.DNS{},
+		"ACL":                         &models.ACL{},
+		"Port_Group":                  &models.PortGroup{},
+		"Address_Set":                 &models.AddressSet{},
+		"Meter":                       &models.Meter{},
+		"Meter_Band":                  &models.MeterBand{},
+		"Logical_Router":              &models.LogicalRouter{},
+		"NAT":                         &models.NAT{},
+		"Logical_Router_Port":         &models.LogicalRouterPort{},
+		"Gateway_Chassis":             &models.GatewayChassis{},
+		"Logical_Router_Static_Route": &models.LogicalRouterStaticRoute{},
+		"Logical_Router_Policy":       &models.LogicalRouterPolicy{},
+		"Load_Balancer":               &models.LoadBalancer{},
+		"Load_Balancer_Group":         &models.LoadBalancerGroup{},
+		"Load_Balancer_Health_Check":  &models.LoadBalancerHealthCheck{},
+		"Chassis_Template_Var":        &models.ChassisTemplateVar{},
+		"QoS":                         &models.QoS{},
+		"Mirror":                      &models.Mirror{},
+		"Copp":                        &models.Copp{},
 		// Add other table mappings
 	})
 	if err != nil {
@@ -26,6 +48,9 @@ func CreateOvnNbClient(nbEndpoint string) (*Client, error) {
 		"Logical_Switch": {
 			{Columns: []model.ColumnKey{{Column: "name"}}},
 		},
+		"Logical_Router": {
+			{Columns: []model.ColumnKey{{Column: "name"}}},
+		},
 	})
 
 	// Create client with connection options
@@ -53,3 +78,33 @@ func CreateOvnNbClient(nbEndpoint string) (*Client, error) {
 func (c *Client) Close() {
 	c.nbClient.Disconnect()
 }
+
+func (c *Client) getLogicalSwitch(ctx context.Context, lsName string) (*models.LogicalSwitch, error) {
+	results := []models.LogicalSwitch{}
+	err := c.nbClient.WhereCache(func(ls *models.LogicalSwitch) bool {
+		return ls.Name == lsName
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logical switch cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("logical switch %q not found", lsName)
+	}
+	return &results[0], nil
+}
+
+func (c *Client) transact(ctx context.Context, ops ...ovsdb.Operation) error {
+	reply, err := c.nbClient.Transact(ctx, ops...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVN NBDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	if _, err := ovsdb.CheckOperationResults(reply, ops); err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+)
+
+func (c *Client) getACLs(ctx context.Context, uuids []string) ([]*models.ACL, error) {
+	acls := []*models.ACL{}
+	for _, u := range uuids {
+		acl := &models.ACL{UUID: u}
+		if err := c.nbClient.Get(ctx, acl); err != nil {
+			return nil, fmt.Errorf("failed to find acl %s: %v", u, err)
+		}
+		acls = append(acls, acl)
+	}
+	return acls, nil
+}
+
+// sameACLs reports whether both lists hold the same rules, ignoring UUIDs and order.
+func sameACLs(a, b []*models.ACL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	keys := func(acls []*models.ACL) []string {
+		out := []string{}
+		for _, acl := range acls {
+			out = append(out, aclKey(acl))
+		}
+		slices.Sort(out)
+		return out
+	}
+	return slices.Equal(keys(a), keys(b))
+}
+
+func aclKey(acl *models.ACL) string {
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return fmt.Sprintf("%s|%s|%d|%d|%s|%t|%s|%s|%s|%v|%v",
+		acl.Direction, acl.Action, acl.Priority, acl.Tier, acl.Match, acl.Log,
+		deref(acl.Name), deref(acl.Severity), deref(acl.Meter), acl.Options, acl.ExternalIDs)
+}
@@ -0,0 +1,102 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// EnsureAddressSet creates the address set if it does not exist and sets its
+// addresses and external_ids.
+func (c *Client) EnsureAddressSet(name string, externalIDs map[string]string, addresses []string) error {
+	ctx := context.Background()
+	if addresses == nil {
+		addresses = []string{}
+	}
+
+	as, err := c.getAddressSet(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	var ops []ovsdb.Operation
+	if as == nil {
+		as = &models.AddressSet{
+			UUID:        uuid.New().String(),
+			Name:        name,
+			ExternalIDs: externalIDs,
+			Addresses:   addresses,
+		}
+		ops, err = c.nbClient.Create(as)
+		if err != nil {
+			return fmt.Errorf("failed to create address set %s: %v", name, err)
+		}
+	} else {
+		if sameSet(as.Addresses, addresses) && mapsEqual(as.ExternalIDs, externalIDs) {
+			return nil
+		}
+		as.Addresses = addresses
+		as.ExternalIDs = externalIDs
+		ops, err = c.nbClient.Where(as).Update(as, &as.Addresses, &as.ExternalIDs)
+		if err != nil {
+			return fmt.Errorf("failed to prepare address set %s update: %v", name, err)
+		}
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Synced address set %s (%d addresses)", name, len(addresses))
+	return nil
+}
+
+func (c *Client) DeleteAddressSet(name string) error {
+	ctx := context.Background()
+
+	as, err := c.getAddressSet(ctx, name)
+	if err != nil {
+		return err
+	}
+	if as == nil {
+		return nil
+	}
+	delOps, err := c.nbClient.Where(as).Delete()
+	if err != nil {
+		return fmt.Errorf("failed to prepare address set %s delete: %v", name, err)
+	}
+	if err := c.transact(ctx, delOps...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted address set %s", name)
+	return nil
+}
+
+// ListAddressSets returns the address sets whose external_ids[key] equals value.
+func (c *Client) ListAddressSets(key, value string) ([]models.AddressSet, error) {
+	results := []models.AddressSet{}
+	err := c.nbClient.WhereCache(func(as *models.AddressSet) bool {
+		return as.ExternalIDs[key] == value
+	}).List(context.Background(), &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query address set cache: %v", err)
+	}
+	return results, nil
+}
+
+func (c *Client) getAddressSet(ctx context.Context, name string) (*models.AddressSet, error) {
+	results := []models.AddressSet{}
+	err := c.nbClient.WhereCache(func(as *models.AddressSet) bool {
+		return as.Name == name
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query address set cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
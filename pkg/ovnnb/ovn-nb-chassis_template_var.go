@@ -0,0 +1,150 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// EnsureChassisTemplateVars sets the given template variables and
+// external_ids on the chassis row, creating it if needed. Other variables
+// and external_ids of the row are left to their owners.
+func (c *Client) EnsureChassisTemplateVars(chassis string, externalIDs, variables map[string]string) error {
+	ctx := context.Background()
+
+	tv, err := c.getChassisTemplateVar(ctx, chassis)
+	if err != nil {
+		return err
+	}
+
+	var ops []ovsdb.Operation
+	if tv == nil {
+		tv = &models.ChassisTemplateVar{
+			UUID:        uuid.New().String(),
+			Chassis:     chassis,
+			ExternalIDs: externalIDs,
+			Variables:   variables,
+		}
+		ops, err = c.nbClient.Create(tv)
+		if err != nil {
+			return fmt.Errorf("failed to create template vars of chassis %s: %v", chassis, err)
+		}
+	} else {
+		mutations := mergeMapMutations(&tv.Variables, tv.Variables, variables)
+		mutations = append(mutations, mergeMapMutations(&tv.ExternalIDs, tv.ExternalIDs, externalIDs)...)
+		if len(mutations) == 0 {
+			return nil
+		}
+		ops, err = c.nbClient.Where(tv).Mutate(tv, mutations...)
+		if err != nil {
+			return fmt.Errorf("failed to prepare template vars of chassis %s mutation: %v", chassis, err)
+		}
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Synced template vars %v of chassis %s", variables, chassis)
+	return nil
+}
+
+// DeleteChassisTemplateVars removes the given template variables and
+// external_ids keys from the chassis row, and the row once it holds no
+// variables anymore.
+func (c *Client) DeleteChassisTemplateVars(chassis string, externalIDKeys, variables []string) error {
+	ctx := context.Background()
+
+	tv, err := c.getChassisTemplateVar(ctx, chassis)
+	if err != nil {
+		return err
+	}
+	if tv == nil {
+		return nil
+	}
+	remaining := 0
+	for name := range tv.Variables {
+		if !slices.Contains(variables, name) {
+			remaining++
+		}
+	}
+
+	var ops []ovsdb.Operation
+	if remaining == 0 {
+		ops, err = c.nbClient.Where(tv).Delete()
+		if err != nil {
+			return fmt.Errorf("failed to prepare template vars of chassis %s delete: %v", chassis, err)
+		}
+	} else {
+		ops, err = c.nbClient.Where(tv).Mutate(tv,
+			model.Mutation{Field: &tv.Variables, Mutator: ovsdb.MutateOperationDelete, Value: variables},
+			model.Mutation{Field: &tv.ExternalIDs, Mutator: ovsdb.MutateOperationDelete, Value: externalIDKeys},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to prepare template vars of chassis %s mutation: %v", chassis, err)
+		}
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted template vars %v of chassis %s", variables, chassis)
+	return nil
+}
+
+// mergeMapMutations returns the mutations setting the entries of want in the
+// map column field, whose current value is current. Changed keys are deleted
+// first, since an insert does not replace existing keys.
+func mergeMapMutations(field *map[string]string, current, want map[string]string) []model.Mutation {
+	keys := []string{}
+	changed := map[string]string{}
+	for k, v := range want {
+		old, ok := current[k]
+		if ok && old == v {
+			continue
+		}
+		if ok {
+			keys = append(keys, k)
+		}
+		changed[k] = v
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	mutations := []model.Mutation{}
+	if len(keys) > 0 {
+		mutations = append(mutations, model.Mutation{Field: field, Mutator: ovsdb.MutateOperationDelete, Value: keys})
+	}
+	return append(mutations, model.Mutation{Field: field, Mutator: ovsdb.MutateOperationInsert, Value: changed})
+}
+
+// ListChassisTemplateVars returns the template variable rows whose
+// external_ids[key] equals value.
+func (c *Client) ListChassisTemplateVars(key, value string) ([]models.ChassisTemplateVar, error) {
+	results := []models.ChassisTemplateVar{}
+	err := c.nbClient.WhereCache(func(tv *models.ChassisTemplateVar) bool {
+		return tv.ExternalIDs[key] == value
+	}).List(context.Background(), &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chassis template var cache: %v", err)
+	}
+	return results, nil
+}
+
+func (c *Client) getChassisTemplateVar(ctx context.Context, chassis string) (*models.ChassisTemplateVar, error) {
+	results := []models.ChassisTemplateVar{}
+	err := c.nbClient.WhereCache(func(tv *models.ChassisTemplateVar) bool {
+		return tv.Chassis == chassis
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chassis template var cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
@@ -0,0 +1,195 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// CoppProtocols are the control plane protocols a CoPP policy can meter,
+// keyed as in the Copp meters column.
+var CoppProtocols = []string{
+	"arp", "arp-resolve", "bfd", "dhcpv4-opts", "dhcpv6-opts", "dns",
+	"event-elb", "icmp4-error", "icmp6-error", "igmp", "nd-na", "nd-ns",
+	"nd-ns-resolve", "nd-ra-opts", "reject", "svc-monitor", "tcp-reset",
+}
+
+// EnsureCopp creates or updates the control plane protection policy, meters
+// mapping a protocol of CoppProtocols to the name of its meter.
+func (c *Client) EnsureCopp(name string, meters, externalIDs map[string]string) error {
+	ctx := context.Background()
+
+	copp, err := c.getCopp(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	var ops []ovsdb.Operation
+	if copp == nil {
+		ops, err = c.nbClient.Create(&models.Copp{
+			UUID:        uuid.New().String(),
+			Name:        name,
+			Meters:      meters,
+			ExternalIDs: externalIDs,
+		})
+	} else {
+		if mapsEqual(copp.Meters, meters) && mapsEqual(copp.ExternalIDs, externalIDs) {
+			return nil
+		}
+		copp.Meters = meters
+		copp.ExternalIDs = externalIDs
+		ops, err = c.nbClient.Where(copp).Update(copp, &copp.Meters, &copp.ExternalIDs)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prepare copp %s: %v", name, err)
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set copp %s to %v", name, meters)
+	return nil
+}
+
+// DeleteCopp detaches the policy from the switches and routers using it and
+// deletes it.
+func (c *Client) DeleteCopp(name string) error {
+	ctx := context.Background()
+
+	copp, err := c.getCopp(ctx, name)
+	if err != nil {
+		return err
+	}
+	if copp == nil {
+		return nil
+	}
+
+	// Copp is a root table referenced strongly, drop the references first.
+	var ops []ovsdb.Operation
+	switches := []models.LogicalSwitch{}
+	if err := c.nbClient.WhereCache(func(ls *models.LogicalSwitch) bool {
+		return ls.Copp != nil && *ls.Copp == copp.UUID
+	}).List(ctx, &switches); err != nil {
+		return fmt.Errorf("failed to query logical switch cache: %v", err)
+	}
+	for i := range switches {
+		ls := &switches[i]
+		ls.Copp = nil
+		lsOps, err := c.nbClient.Where(ls).Update(ls, &ls.Copp)
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical switch %s update: %v", ls.Name, err)
+		}
+		ops = append(ops, lsOps...)
+	}
+	routers := []models.LogicalRouter{}
+	if err := c.nbClient.WhereCache(func(lr *models.LogicalRouter) bool {
+		return lr.Copp != nil && *lr.Copp == copp.UUID
+	}).List(ctx, &routers); err != nil {
+		return fmt.Errorf("failed to query logical router cache: %v", err)
+	}
+	for i := range routers {
+		lr := &routers[i]
+		lr.Copp = nil
+		lrOps, err := c.nbClient.Where(lr).Update(lr, &lr.Copp)
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical router %s update: %v", lr.Name, err)
+		}
+		ops = append(ops, lrOps...)
+	}
+	delOps, err := c.nbClient.Where(copp).Delete()
+	if err != nil {
+		return fmt.Errorf("failed to prepare copp delete: %v", err)
+	}
+	if err := c.transact(ctx, append(ops, delOps...)...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted copp %s", name)
+	return nil
+}
+
+// SetLogicalSwitchCopp attaches the CoPP policy to the logical switch, or
+// detaches any policy when coppName is empty.
+func (c *Client) SetLogicalSwitchCopp(lsName, coppName string) error {
+	ctx := context.Background()
+
+	ls, err := c.getLogicalSwitch(ctx, lsName)
+	if err != nil {
+		return err
+	}
+	coppUUID, err := c.coppUUID(ctx, coppName)
+	if err != nil {
+		return err
+	}
+	if equalPtr(ls.Copp, coppUUID) {
+		return nil
+	}
+	ls.Copp = coppUUID
+	ops, err := c.nbClient.Where(ls).Update(ls, &ls.Copp)
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical switch %s update: %v", lsName, err)
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set copp %q on logicalswitch %s", coppName, lsName)
+	return nil
+}
+
+// SetLogicalRouterCopp attaches the CoPP policy to the logical router, or
+// detaches any policy when coppName is empty.
+func (c *Client) SetLogicalRouterCopp(lrName, coppName string) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	coppUUID, err := c.coppUUID(ctx, coppName)
+	if err != nil {
+		return err
+	}
+	if equalPtr(lr.Copp, coppUUID) {
+		return nil
+	}
+	lr.Copp = coppUUID
+	ops, err := c.nbClient.Where(lr).Update(lr, &lr.Copp)
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical router %s update: %v", lrName, err)
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set copp %q on logicalrouter %s", coppName, lrName)
+	return nil
+}
+
+func (c *Client) coppUUID(ctx context.Context, name string) (*string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	copp, err := c.getCopp(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if copp == nil {
+		return nil, fmt.Errorf("copp %q not found", name)
+	}
+	return &copp.UUID, nil
+}
+
+func (c *Client) getCopp(ctx context.Context, name string) (*models.Copp, error) {
+	results := []models.Copp{}
+	err := c.nbClient.WhereCache(func(copp *models.Copp) bool {
+		return copp.Name == name
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query copp cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
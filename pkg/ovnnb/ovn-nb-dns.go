@@ -0,0 +1,126 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// dnsOwnerKey marks the DNS row managed by the plugin for a logical switch.
+const dnsOwnerKey = "ovn.ik8s.ir/logical-switch"
+
+// AddDNSRecords points each hostname at ip in the DNS row of the logical switch,
+// creating the row and referencing it from Logical_Switch.dns_records if needed.
+func (c *Client) AddDNSRecords(lsName string, hostnames []string, ip string) error {
+	ctx := context.Background()
+
+	ls, err := c.getLogicalSwitch(ctx, lsName)
+	if err != nil {
+		return err
+	}
+
+	records := map[string]string{}
+	for _, h := range hostnames {
+		records[h] = ip
+	}
+
+	dns, err := c.getSwitchDNS(ctx, lsName)
+	if err != nil {
+		return err
+	}
+
+	var ops []ovsdb.Operation
+	if dns == nil {
+		// Create the row and reference it from the switch in one transaction
+		// so that a failure cannot leave an orphan DNS row behind.
+		dns = &models.DNS{
+			UUID:        uuid.New().String(),
+			Records:     records,
+			ExternalIDs: map[string]string{dnsOwnerKey: lsName},
+		}
+		dnsOp, err := c.nbClient.Create(dns)
+		if err != nil {
+			return fmt.Errorf("failed to create dns row: %v", err)
+		}
+		mutateOps, err := c.nbClient.Where(ls).Mutate(ls, model.Mutation{
+			Field:   &ls.DNSRecords,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   []string{dns.UUID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical switch mutation: %v", err)
+		}
+		ops = append(dnsOp, mutateOps...)
+	} else {
+		// Map inserts never overwrite existing keys, so drop stale entries first.
+		mutateOps, err := c.nbClient.Where(dns).Mutate(dns,
+			model.Mutation{
+				Field:   &dns.Records,
+				Mutator: ovsdb.MutateOperationDelete,
+				Value:   hostnames,
+			},
+			model.Mutation{
+				Field:   &dns.Records,
+				Mutator: ovsdb.MutateOperationInsert,
+				Value:   records,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to prepare dns mutation: %v", err)
+		}
+		ops = mutateOps
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Added dns records %v -> %s on logicalswitch %s", hostnames, ip, lsName)
+	return nil
+}
+
+// DeleteDNSRecords removes the hostnames from the DNS row of the logical switch.
+func (c *Client) DeleteDNSRecords(lsName string, hostnames []string) error {
+	ctx := context.Background()
+
+	dns, err := c.getSwitchDNS(ctx, lsName)
+	if err != nil {
+		return err
+	}
+	if dns == nil {
+		log.Printf("⚠️ No dns row for switch %q, skipping delete", lsName)
+		return nil
+	}
+
+	mutateOps, err := c.nbClient.Where(dns).Mutate(dns, model.Mutation{
+		Field:   &dns.Records,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   hostnames,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare dns mutation: %v", err)
+	}
+	if err := c.transact(ctx, mutateOps...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted dns records %v from switch %s", hostnames, lsName)
+	return nil
+}
+
+func (c *Client) getSwitchDNS(ctx context.Context, lsName string) (*models.DNS, error) {
+	results := []models.DNS{}
+	err := c.nbClient.WhereCache(func(d *models.DNS) bool {
+		return d.ExternalIDs[dnsOwnerKey] == lsName
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dns cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
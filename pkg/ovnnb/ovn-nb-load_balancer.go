@@ -0,0 +1,226 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// EnsureLoadBalancer creates the load balancer, or updates the one with the
+// same name, and makes it a member of the load balancer group when groupName
+// is set. The health checks replace those of the load balancer; backends are
+// only monitored when lb.IPPortMappings maps them to a logical port.
+func (c *Client) EnsureLoadBalancer(groupName string, lb *models.LoadBalancer, healthChecks []*models.LoadBalancerHealthCheck) error {
+	ctx := context.Background()
+	if lb.Vips == nil {
+		lb.Vips = map[string]string{}
+	}
+
+	existing, err := c.getLoadBalancer(ctx, lb.Name)
+	if err != nil {
+		return err
+	}
+
+	var current []string
+	if existing != nil {
+		current = existing.HealthCheck
+	}
+	hcUUIDs, ops, err := c.healthCheckOps(ctx, current, healthChecks)
+	if err != nil {
+		return err
+	}
+	lb.HealthCheck = hcUUIDs
+
+	if existing == nil {
+		lb.UUID = uuid.New().String()
+		createOps, err := c.nbClient.Create(lb)
+		if err != nil {
+			return fmt.Errorf("failed to create load balancer %s: %v", lb.Name, err)
+		}
+		ops = append(ops, createOps...)
+	} else {
+		lb.UUID = existing.UUID
+		if !mapsEqual(existing.Vips, lb.Vips) || !equalPtr(existing.Protocol, lb.Protocol) ||
+			!mapsEqual(existing.Options, lb.Options) || !mapsEqual(existing.ExternalIDs, lb.ExternalIDs) ||
+			!mapsEqual(existing.IPPortMappings, lb.IPPortMappings) || !sameSet(existing.HealthCheck, lb.HealthCheck) {
+			updateOps, err := c.nbClient.Where(lb).Update(lb, &lb.Vips, &lb.Protocol, &lb.Options, &lb.ExternalIDs,
+				&lb.IPPortMappings, &lb.HealthCheck)
+			if err != nil {
+				return fmt.Errorf("failed to prepare load balancer %s update: %v", lb.Name, err)
+			}
+			ops = append(ops, updateOps...)
+		}
+	}
+
+	if groupName != "" {
+		group, err := c.getLoadBalancerGroup(ctx, groupName)
+		if err != nil {
+			return err
+		}
+		if group == nil {
+			return fmt.Errorf("load balancer group %q not found", groupName)
+		}
+		if !slices.Contains(group.LoadBalancer, lb.UUID) {
+			mutateOps, err := c.nbClient.Where(group).Mutate(group, model.Mutation{
+				Field:   &group.LoadBalancer,
+				Mutator: ovsdb.MutateOperationInsert,
+				Value:   []string{lb.UUID},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to prepare load balancer group mutation: %v", err)
+			}
+			ops = append(ops, mutateOps...)
+		}
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Synced load balancer %s (%d vips)", lb.Name, len(lb.Vips))
+	return nil
+}
+
+// DeleteLoadBalancer removes the load balancer together with its references
+// from load balancer groups, switches and routers.
+func (c *Client) DeleteLoadBalancer(name string) error {
+	ctx := context.Background()
+
+	lb, err := c.getLoadBalancer(ctx, name)
+	if err != nil {
+		return err
+	}
+	if lb == nil {
+		return nil
+	}
+	// Load_Balancer is a root table referenced strongly, so every reference
+	// must go in the same transaction as the row.
+	remove := model.Mutation{Mutator: ovsdb.MutateOperationDelete, Value: []string{lb.UUID}}
+	var ops []ovsdb.Operation
+
+	groups := []models.LoadBalancerGroup{}
+	err = c.nbClient.WhereCache(func(g *models.LoadBalancerGroup) bool {
+		return slices.Contains(g.LoadBalancer, lb.UUID)
+	}).List(ctx, &groups)
+	if err != nil {
+		return fmt.Errorf("failed to query load balancer group cache: %v", err)
+	}
+	for i := range groups {
+		remove.Field = &groups[i].LoadBalancer
+		mutateOps, err := c.nbClient.Where(&groups[i]).Mutate(&groups[i], remove)
+		if err != nil {
+			return fmt.Errorf("failed to prepare load balancer group mutation: %v", err)
+		}
+		ops = append(ops, mutateOps...)
+	}
+
+	switches := []models.LogicalSwitch{}
+	err = c.nbClient.WhereCache(func(ls *models.LogicalSwitch) bool {
+		return slices.Contains(ls.LoadBalancer, lb.UUID)
+	}).List(ctx, &switches)
+	if err != nil {
+		return fmt.Errorf("failed to query logical switch cache: %v", err)
+	}
+	for i := range switches {
+		remove.Field = &switches[i].LoadBalancer
+		mutateOps, err := c.nbClient.Where(&switches[i]).Mutate(&switches[i], remove)
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical switch mutation: %v", err)
+		}
+		ops = append(ops, mutateOps...)
+	}
+
+	routers := []models.LogicalRouter{}
+	err = c.nbClient.WhereCache(func(lr *models.LogicalRouter) bool {
+		return slices.Contains(lr.LoadBalancer, lb.UUID)
+	}).List(ctx, &routers)
+	if err != nil {
+		return fmt.Errorf("failed to query logical router cache: %v", err)
+	}
+	for i := range routers {
+		remove.Field = &routers[i].LoadBalancer
+		mutateOps, err := c.nbClient.Where(&routers[i]).Mutate(&routers[i], remove)
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+		}
+		ops = append(ops, mutateOps...)
+	}
+
+	delOps, err := c.nbClient.Where(lb).Delete()
+	if err != nil {
+		return fmt.Errorf("failed to prepare load balancer %s delete: %v", name, err)
+	}
+	if err := c.transact(ctx, append(ops, delOps...)...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted load balancer %s", name)
+	return nil
+}
+
+// ListLoadBalancers returns the load balancers whose external_ids[key] equals value.
+func (c *Client) ListLoadBalancers(key, value string) ([]models.LoadBalancer, error) {
+	results := []models.LoadBalancer{}
+	err := c.nbClient.WhereCache(func(lb *models.LoadBalancer) bool {
+		return lb.ExternalIDs[key] == value
+	}).List(context.Background(), &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query load balancer cache: %v", err)
+	}
+	return results, nil
+}
+
+func (c *Client) getLoadBalancer(ctx context.Context, name string) (*models.LoadBalancer, error) {
+	results := []models.LoadBalancer{}
+	err := c.nbClient.WhereCache(func(lb *models.LoadBalancer) bool {
+		return lb.Name == name
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query load balancer cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+// AddLoadBalancerToRouter applies the load balancer to the traffic routed by
+// the logical router, typically a gateway router facing the external network.
+func (c *Client) AddLoadBalancerToRouter(lrName, lbName string) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	lb, err := c.getLoadBalancer(ctx, lbName)
+	if err != nil {
+		return err
+	}
+	if lb == nil {
+		return fmt.Errorf("load balancer %q not found", lbName)
+	}
+	if slices.Contains(lr.LoadBalancer, lb.UUID) {
+		return nil
+	}
+	mutateOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+		Field:   &lr.LoadBalancer,
+		Mutator: ovsdb.MutateOperationInsert,
+		Value:   []string{lb.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+	}
+	if err := c.transact(ctx, mutateOps...); err != nil {
+		return err
+	}
+	log.Printf("✅ Added load balancer %s to logicalrouter %s", lbName, lrName)
+	return nil
+}
@@ -0,0 +1,120 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// EnsureLoadBalancerGroup creates the load balancer group unless it already exists.
+func (c *Client) EnsureLoadBalancerGroup(name string) error {
+	ctx := context.Background()
+
+	group, err := c.getLoadBalancerGroup(ctx, name)
+	if err != nil {
+		return err
+	}
+	if group != nil {
+		return nil
+	}
+	ops, err := c.nbClient.Create(&models.LoadBalancerGroup{
+		UUID: uuid.New().String(),
+		Name: name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create load balancer group %s: %v", name, err)
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Created load balancer group %s", name)
+	return nil
+}
+
+// AddLoadBalancerGroupToSwitch applies the load balancers of the group to the
+// traffic entering the logical switch.
+func (c *Client) AddLoadBalancerGroupToSwitch(lsName, groupName string) error {
+	ctx := context.Background()
+
+	ls, err := c.getLogicalSwitch(ctx, lsName)
+	if err != nil {
+		return err
+	}
+	group, err := c.getLoadBalancerGroup(ctx, groupName)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return fmt.Errorf("load balancer group %q not found", groupName)
+	}
+	if slices.Contains(ls.LoadBalancerGroup, group.UUID) {
+		return nil
+	}
+	mutateOps, err := c.nbClient.Where(ls).Mutate(ls, model.Mutation{
+		Field:   &ls.LoadBalancerGroup,
+		Mutator: ovsdb.MutateOperationInsert,
+		Value:   []string{group.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical switch mutation: %v", err)
+	}
+	if err := c.transact(ctx, mutateOps...); err != nil {
+		return err
+	}
+	log.Printf("✅ Added load balancer group %s to logicalswitch %s", groupName, lsName)
+	return nil
+}
+
+// AddLoadBalancerGroupToRouter applies the load balancers of the group to the
+// traffic routed by the logical router.
+func (c *Client) AddLoadBalancerGroupToRouter(lrName, groupName string) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	group, err := c.getLoadBalancerGroup(ctx, groupName)
+	if err != nil {
+		return err
+	}
+	if group == nil {
+		return fmt.Errorf("load balancer group %q not found", groupName)
+	}
+	if slices.Contains(lr.LoadBalancerGroup, group.UUID) {
+		return nil
+	}
+	mutateOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+		Field:   &lr.LoadBalancerGroup,
+		Mutator: ovsdb.MutateOperationInsert,
+		Value:   []string{group.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+	}
+	if err := c.transact(ctx, mutateOps...); err != nil {
+		return err
+	}
+	log.Printf("✅ Added load balancer group %s to logicalrouter %s", groupName, lrName)
+	return nil
+}
+
+func (c *Client) getLoadBalancerGroup(ctx context.Context, name string) (*models.LoadBalancerGroup, error) {
+	results := []models.LoadBalancerGroup{}
+	err := c.nbClient.WhereCache(func(g *models.LoadBalancerGroup) bool {
+		return g.Name == name
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query load balancer group cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
@@ -0,0 +1,54 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// healthCheckOps returns the health check uuids a load balancer should
+// reference, creating new rows when the checks differ from the current ones.
+// Load_Balancer_Health_Check is not a root table, so replaced rows are
+// garbage collected once the load balancer stops referencing them.
+func (c *Client) healthCheckOps(ctx context.Context, current []string, healthChecks []*models.LoadBalancerHealthCheck) ([]string, []ovsdb.Operation, error) {
+	existing := map[string]bool{}
+	for _, u := range current {
+		hc := models.LoadBalancerHealthCheck{UUID: u}
+		if err := c.nbClient.Get(ctx, &hc); err != nil {
+			return nil, nil, fmt.Errorf("failed to find load balancer health check %s: %v", u, err)
+		}
+		existing[healthCheckKey(&hc)] = true
+	}
+	if len(existing) == len(healthChecks) {
+		same := true
+		for _, hc := range healthChecks {
+			if !existing[healthCheckKey(hc)] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return current, nil, nil
+		}
+	}
+
+	uuids := []string{}
+	var ops []ovsdb.Operation
+	for _, hc := range healthChecks {
+		hc.UUID = uuid.New().String()
+		hcOps, err := c.nbClient.Create(hc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create load balancer health check %s: %v", hc.Vip, err)
+		}
+		ops = append(ops, hcOps...)
+		uuids = append(uuids, hc.UUID)
+	}
+	return uuids, ops, nil
+}
+
+func healthCheckKey(hc *models.LoadBalancerHealthCheck) string {
+	return fmt.Sprintf("%s|%v|%v", hc.Vip, hc.Options, hc.ExternalIDs)
+}
@@ -0,0 +1,87 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// LocalnetPortName returns the name of the localnet port of a provider
+// network switch.
+func LocalnetPortName(lsName string) string { return "ln-" + lsName }
+
+// EnsureLocalnetPort turns the logical switch into a provider network: its
+// localnet port bridges it to networkName, mapped to a physical bridge by
+// ovn-bridge-mappings on every chassis. A non-zero vlan tags the traffic
+// leaving through the port.
+func (c *Client) EnsureLocalnetPort(lsName, networkName string, vlan int) error {
+	ctx := context.Background()
+
+	ls, err := c.getLogicalSwitch(ctx, lsName)
+	if err != nil {
+		return err
+	}
+	lspName := LocalnetPortName(lsName)
+	results := []models.LogicalSwitchPort{}
+	err = c.nbClient.WhereCache(func(lsp *models.LogicalSwitchPort) bool {
+		return lsp.Name == lspName
+	}).List(ctx, &results)
+	if err != nil {
+		return fmt.Errorf("failed to query logical switch port cache: %v", err)
+	}
+	var tag *int
+	if vlan > 0 {
+		tag = &vlan
+	}
+	options := map[string]string{"network_name": networkName}
+
+	var ops []ovsdb.Operation
+	if len(results) == 0 {
+		lsp := &models.LogicalSwitchPort{
+			UUID:      uuid.New().String(),
+			Name:      lspName,
+			Type:      "localnet",
+			Addresses: []string{"unknown"},
+			Options:   options,
+			Tag:       tag,
+		}
+		lspOps, err := c.nbClient.Create(lsp)
+		if err != nil {
+			return fmt.Errorf("failed to create logical port %s: %v", lspName, err)
+		}
+		mutateOps, err := c.nbClient.Where(ls).Mutate(ls, model.Mutation{
+			Field:   &ls.Ports,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   []string{lsp.UUID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare mutation: %v", err)
+		}
+		ops = append(lspOps, mutateOps...)
+	} else {
+		lsp := &results[0]
+		if lsp.Options["network_name"] == networkName && equalPtr(lsp.Tag, tag) {
+			return nil
+		}
+		if lsp.Options == nil {
+			lsp.Options = map[string]string{}
+		}
+		lsp.Options["network_name"] = networkName
+		lsp.Tag = tag
+		ops, err = c.nbClient.Where(lsp).Update(lsp, &lsp.Options, &lsp.Tag)
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical port %s update: %v", lspName, err)
+		}
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Connected logicalswitch %s to provider network %s (vlan %d)", lsName, networkName, vlan)
+	return nil
+}
@@ -0,0 +1,68 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+)
+
+// CreateLogicalRouter creates the logical router unless it already exists.
+func (c *Client) CreateLogicalRouter(lrName string, options, externalIDs map[string]string) error {
+	ctx := context.Background()
+
+	if _, err := c.getLogicalRouter(ctx, lrName); err == nil {
+		return nil
+	}
+	ops, err := c.nbClient.Create(&models.LogicalRouter{
+		UUID:        uuid.New().String(),
+		Name:        lrName,
+		Options:     options,
+		ExternalIDs: externalIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create logical router %s: %v", lrName, err)
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Created logicalrouter %s", lrName)
+	return nil
+}
+
+// DeleteLogicalRouter deletes the logical router together with its ports,
+// NAT rules, static routes and policies.
+func (c *Client) DeleteLogicalRouter(lrName string) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		log.Printf("⚠️ Logical router %q not found, skipping delete", lrName)
+		return nil
+	}
+	delOps, err := c.nbClient.Where(lr).Delete()
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical router delete: %v", err)
+	}
+	if err := c.transact(ctx, delOps...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted logicalrouter %s", lrName)
+	return nil
+}
+
+func (c *Client) getLogicalRouter(ctx context.Context, lrName string) (*models.LogicalRouter, error) {
+	results := []models.LogicalRouter{}
+	err := c.nbClient.WhereCache(func(lr *models.LogicalRouter) bool {
+		return lr.Name == lrName
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logical router cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("logical router %q not found", lrName)
+	}
+	return &results[0], nil
+}
@@ -0,0 +1,107 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// AddOrUpdateRouterPolicy creates the policy on the logical router, or
+// updates the policy with the same priority and match.
+func (c *Client) AddOrUpdateRouterPolicy(lrName string, policy *models.LogicalRouterPolicy) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	existing, err := c.findRouterPolicy(ctx, lr, policy.Priority, policy.Match)
+	if err != nil {
+		return err
+	}
+
+	var ops []ovsdb.Operation
+	if existing == nil {
+		// Logical_Router_Policy is not a root table, so create and reference it together.
+		policy.UUID = uuid.New().String()
+		policyOps, err := c.nbClient.Create(policy)
+		if err != nil {
+			return fmt.Errorf("failed to create router policy %q: %v", policy.Match, err)
+		}
+		mutateOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+			Field:   &lr.Policies,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   []string{policy.UUID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+		}
+		ops = append(policyOps, mutateOps...)
+	} else {
+		if existing.Action == policy.Action && sameSet(existing.Nexthops, policy.Nexthops) &&
+			mapsEqual(existing.ExternalIDs, policy.ExternalIDs) && mapsEqual(existing.Options, policy.Options) {
+			return nil
+		}
+		policy.UUID = existing.UUID
+		ops, err = c.nbClient.Where(policy).Update(policy, &policy.Action, &policy.Nexthops, &policy.ExternalIDs, &policy.Options)
+		if err != nil {
+			return fmt.Errorf("failed to prepare router policy %q update: %v", policy.Match, err)
+		}
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set policy %d %q -> %s on router %s", policy.Priority, policy.Match, policy.Action, lrName)
+	return nil
+}
+
+// DeleteRouterPolicy removes the policy with the given priority and match.
+func (c *Client) DeleteRouterPolicy(lrName string, priority int, match string) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	policy, err := c.findRouterPolicy(ctx, lr, priority, match)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		log.Printf("⚠️ Policy %d %q not found on router %s, skipping delete", priority, match, lrName)
+		return nil
+	}
+
+	mutateOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+		Field:   &lr.Policies,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   []string{policy.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+	}
+	if err := c.transact(ctx, mutateOps...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted policy %d %q from router %s", priority, match, lrName)
+	return nil
+}
+
+func (c *Client) findRouterPolicy(ctx context.Context, lr *models.LogicalRouter, priority int, match string) (*models.LogicalRouterPolicy, error) {
+	for _, u := range lr.Policies {
+		policy := &models.LogicalRouterPolicy{UUID: u}
+		if err := c.nbClient.Get(ctx, policy); err != nil {
+			return nil, fmt.Errorf("failed to find router policy %s: %v", u, err)
+		}
+		if policy.Priority == priority && policy.Match == match {
+			return policy, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,233 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// CreateLogicalRouterPort creates a port on the logical router, or updates
+// the mac and networks of an existing port with the same name.
+func (c *Client) CreateLogicalRouterPort(lrName, lrpName, mac string, networks []string) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	existing, err := c.getLogicalRouterPort(ctx, lrpName)
+	if err != nil {
+		return err
+	}
+
+	var ops []ovsdb.Operation
+	if existing == nil {
+		// Logical_Router_Port is not a root table, so create and reference it together.
+		lrp := &models.LogicalRouterPort{
+			UUID:     uuid.New().String(),
+			Name:     lrpName,
+			MAC:      mac,
+			Networks: networks,
+		}
+		lrpOps, err := c.nbClient.Create(lrp)
+		if err != nil {
+			return fmt.Errorf("failed to create logical router port %s: %v", lrpName, err)
+		}
+		mutateOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+			Field:   &lr.Ports,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   []string{lrp.UUID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+		}
+		ops = append(lrpOps, mutateOps...)
+	} else {
+		if existing.MAC == mac && sameSet(existing.Networks, networks) {
+			return nil
+		}
+		existing.MAC = mac
+		existing.Networks = networks
+		ops, err = c.nbClient.Where(existing).Update(existing, &existing.MAC, &existing.Networks)
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical router port %s update: %v", lrpName, err)
+		}
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Added logicalrouterport %s to logicalrouter %s", lrpName, lrName)
+	return nil
+}
+
+// DeleteLogicalRouterPort removes the port from the logical router.
+func (c *Client) DeleteLogicalRouterPort(lrName, lrpName string) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	lrp, err := c.getLogicalRouterPort(ctx, lrpName)
+	if err != nil {
+		return err
+	}
+	if lrp == nil {
+		log.Printf("⚠️ Router port %q not found in cache, skipping delete", lrpName)
+		return nil
+	}
+
+	mutateOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+		Field:   &lr.Ports,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   []string{lrp.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+	}
+	if err := c.transact(ctx, mutateOps...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted logical router port %s from router %s", lrpName, lrName)
+	return nil
+}
+
+// SetGatewayChassis replaces the gateway chassis of a router port, turning it
+// into a distributed gateway port. The map is chassis name to priority; an
+// empty map makes the port fully distributed again.
+func (c *Client) SetGatewayChassis(lrpName string, chassis map[string]int) error {
+	ctx := context.Background()
+
+	lrp, err := c.getLogicalRouterPort(ctx, lrpName)
+	if err != nil {
+		return err
+	}
+	if lrp == nil {
+		return fmt.Errorf("logical router port %q not found", lrpName)
+	}
+
+	current := map[string]int{}
+	for _, u := range lrp.GatewayChassis {
+		gc := models.GatewayChassis{UUID: u}
+		if err := c.nbClient.Get(ctx, &gc); err != nil {
+			return fmt.Errorf("failed to find gateway chassis %s: %v", u, err)
+		}
+		current[gc.ChassisName] = gc.Priority
+	}
+	if len(current) == len(chassis) {
+		same := true
+		for name, prio := range chassis {
+			if p, ok := current[name]; !ok || p != prio {
+				same = false
+				break
+			}
+		}
+		if same {
+			return nil
+		}
+	}
+
+	names := make([]string, 0, len(chassis))
+	for name := range chassis {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Gateway_Chassis is not a root table, so the old rows go away once the
+	// port stops referencing them.
+	var ops []ovsdb.Operation
+	uuids := make([]string, 0, len(names))
+	for _, name := range names {
+		gc := &models.GatewayChassis{
+			UUID:        uuid.New().String(),
+			Name:        lrpName + "-" + name,
+			ChassisName: name,
+			Priority:    chassis[name],
+		}
+		gcOps, err := c.nbClient.Create(gc)
+		if err != nil {
+			return fmt.Errorf("failed to create gateway chassis %s: %v", gc.Name, err)
+		}
+		ops = append(ops, gcOps...)
+		uuids = append(uuids, gc.UUID)
+	}
+	lrp.GatewayChassis = uuids
+	updateOps, err := c.nbClient.Where(lrp).Update(lrp, &lrp.GatewayChassis)
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical router port %s update: %v", lrpName, err)
+	}
+	ops = append(ops, updateOps...)
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set gateway chassis %v on logicalrouterport %s", names, lrpName)
+	return nil
+}
+
+// CreateRouterTypePort creates the switch side peer of a router port, which
+// connects the logical switch to the logical router.
+func (c *Client) CreateRouterTypePort(lsName, lspName, lrpName string) error {
+	ctx := context.Background()
+
+	ls, err := c.getLogicalSwitch(ctx, lsName)
+	if err != nil {
+		return err
+	}
+	results := []models.LogicalSwitchPort{}
+	err = c.nbClient.WhereCache(func(lsp *models.LogicalSwitchPort) bool {
+		return lsp.Name == lspName
+	}).List(ctx, &results)
+	if err != nil {
+		return fmt.Errorf("failed to query logical switch port cache: %v", err)
+	}
+	if len(results) > 0 {
+		return nil
+	}
+
+	lsp := &models.LogicalSwitchPort{
+		UUID:      uuid.New().String(),
+		Name:      lspName,
+		Type:      "router",
+		Addresses: []string{"router"},
+		Options:   map[string]string{"router-port": lrpName},
+	}
+	lspOps, err := c.nbClient.Create(lsp)
+	if err != nil {
+		return fmt.Errorf("failed to create logical port %s: %v", lspName, err)
+	}
+	mutateOps, err := c.nbClient.Where(ls).Mutate(ls, model.Mutation{
+		Field:   &ls.Ports,
+		Mutator: ovsdb.MutateOperationInsert,
+		Value:   []string{lsp.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare mutation: %v", err)
+	}
+	if err := c.transact(ctx, append(lspOps, mutateOps...)...); err != nil {
+		return err
+	}
+	log.Printf("✅ Connected logicalswitch %s to logicalrouterport %s", lsName, lrpName)
+	return nil
+}
+
+func (c *Client) getLogicalRouterPort(ctx context.Context, lrpName string) (*models.LogicalRouterPort, error) {
+	results := []models.LogicalRouterPort{}
+	err := c.nbClient.WhereCache(func(lrp *models.LogicalRouterPort) bool {
+		return lrp.Name == lrpName
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logical router port cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
@@ -0,0 +1,182 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// AddStaticRoute adds the static route to the logical router unless a route
+// with the same prefix, nexthop, policy and route table already exists.
+func (c *Client) AddStaticRoute(lrName string, route *models.LogicalRouterStaticRoute) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	existing, err := c.findStaticRoute(ctx, lr, route.IPPrefix, route.Nexthop, route.Policy, route.RouteTable)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+
+	// Logical_Router_Static_Route is not a root table, so create and reference it together.
+	route.UUID = uuid.New().String()
+	routeOps, err := c.nbClient.Create(route)
+	if err != nil {
+		return fmt.Errorf("failed to create static route %s: %v", route.IPPrefix, err)
+	}
+	mutateOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+		Field:   &lr.StaticRoutes,
+		Mutator: ovsdb.MutateOperationInsert,
+		Value:   []string{route.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+	}
+	if err := c.transact(ctx, append(routeOps, mutateOps...)...); err != nil {
+		return err
+	}
+	log.Printf("✅ Added route %s via %s to router %s", route.IPPrefix, route.Nexthop, lrName)
+	return nil
+}
+
+// ReplaceStaticRoute makes the static route the only one of the logical
+// router with its prefix, policy and route table among the routes carrying
+// its external_ids, so that a changed nexthop replaces the previous route
+// instead of adding a second one.
+func (c *Client) ReplaceStaticRoute(lrName string, route *models.LogicalRouterStaticRoute) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	found := false
+	for _, u := range lr.StaticRoutes {
+		existing := &models.LogicalRouterStaticRoute{UUID: u}
+		if err := c.nbClient.Get(ctx, existing); err != nil {
+			return fmt.Errorf("failed to find static route %s: %v", u, err)
+		}
+		if existing.IPPrefix != route.IPPrefix || existing.RouteTable != route.RouteTable ||
+			routePolicy(existing.Policy) != routePolicy(route.Policy) || !hasExternalIDs(existing.ExternalIDs, route.ExternalIDs) {
+			continue
+		}
+		if existing.Nexthop == route.Nexthop && !found {
+			found = true
+			continue
+		}
+		stale = append(stale, existing.UUID)
+	}
+	if found && len(stale) == 0 {
+		return nil
+	}
+
+	var ops []ovsdb.Operation
+	if len(stale) > 0 {
+		deleteOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+			Field:   &lr.StaticRoutes,
+			Mutator: ovsdb.MutateOperationDelete,
+			Value:   stale,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+		}
+		ops = append(ops, deleteOps...)
+	}
+	if !found {
+		route.UUID = uuid.New().String()
+		routeOps, err := c.nbClient.Create(route)
+		if err != nil {
+			return fmt.Errorf("failed to create static route %s: %v", route.IPPrefix, err)
+		}
+		insertOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+			Field:   &lr.StaticRoutes,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   []string{route.UUID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+		}
+		ops = append(append(ops, routeOps...), insertOps...)
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set route %s via %s on router %s, replacing %d route(s)", route.IPPrefix, route.Nexthop, lrName, len(stale))
+	return nil
+}
+
+// DeleteStaticRoute removes the destination based static route with the
+// given prefix and nexthop from the main route table.
+func (c *Client) DeleteStaticRoute(lrName, ipPrefix, nexthop string) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	route, err := c.findStaticRoute(ctx, lr, ipPrefix, nexthop, nil, "")
+	if err != nil {
+		return err
+	}
+	if route == nil {
+		log.Printf("⚠️ Route %s via %s not found on router %s, skipping delete", ipPrefix, nexthop, lrName)
+		return nil
+	}
+
+	mutateOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+		Field:   &lr.StaticRoutes,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   []string{route.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+	}
+	if err := c.transact(ctx, mutateOps...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted route %s via %s from router %s", ipPrefix, nexthop, lrName)
+	return nil
+}
+
+func (c *Client) findStaticRoute(ctx context.Context, lr *models.LogicalRouter, ipPrefix, nexthop string, policy *string, routeTable string) (*models.LogicalRouterStaticRoute, error) {
+	for _, u := range lr.StaticRoutes {
+		route := &models.LogicalRouterStaticRoute{UUID: u}
+		if err := c.nbClient.Get(ctx, route); err != nil {
+			return nil, fmt.Errorf("failed to find static route %s: %v", u, err)
+		}
+		if route.IPPrefix == ipPrefix && route.Nexthop == nexthop && route.RouteTable == routeTable &&
+			routePolicy(route.Policy) == routePolicy(policy) {
+			return route, nil
+		}
+	}
+	return nil, nil
+}
+
+// routePolicy resolves an unset policy to its dst-ip default.
+func routePolicy(policy *string) string {
+	if policy == nil {
+		return models.LogicalRouterStaticRoutePolicyDstIP
+	}
+	return *policy
+}
+
+// hasExternalIDs reports whether ids contains every entry of want.
+func hasExternalIDs(ids, want map[string]string) bool {
+	for k, v := range want {
+		if ids[k] != v {
+			return false
+		}
+	}
+	return true
+}
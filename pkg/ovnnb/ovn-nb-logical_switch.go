@@ -0,0 +1,112 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// CreateLogicalSwitch creates the logical switch unless it already exists.
+func (c *Client) CreateLogicalSwitch(lsName string, externalIDs map[string]string) error {
+	ctx := context.Background()
+
+	if _, err := c.getLogicalSwitch(ctx, lsName); err == nil {
+		return nil
+	}
+	ops, err := c.nbClient.Create(&models.LogicalSwitch{
+		UUID:        uuid.New().String(),
+		Name:        lsName,
+		ExternalIDs: externalIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create logical switch %s: %v", lsName, err)
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Created logicalswitch %s", lsName)
+	return nil
+}
+
+// DeleteLogicalSwitch deletes the logical switch together with its ports.
+func (c *Client) DeleteLogicalSwitch(lsName string) error {
+	ctx := context.Background()
+
+	ls, err := c.getLogicalSwitch(ctx, lsName)
+	if err != nil {
+		log.Printf("⚠️ Logical switch %q not found, skipping delete", lsName)
+		return nil
+	}
+	delOps, err := c.nbClient.Where(ls).Delete()
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical switch delete: %v", err)
+	}
+	if err := c.transact(ctx, delOps...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted logicalswitch %s", lsName)
+	return nil
+}
+
+// SetLogicalSwitchVLANPassthru lets the ports of the switch send and receive
+// VLAN tagged frames as they are, instead of OVN dropping them.
+func (c *Client) SetLogicalSwitchVLANPassthru(lsName string, enabled bool) error {
+	ctx := context.Background()
+
+	ls, err := c.getLogicalSwitch(ctx, lsName)
+	if err != nil {
+		return err
+	}
+	if (ls.OtherConfig["vlan-passthru"] == "true") == enabled {
+		return nil
+	}
+	mutations := []model.Mutation{{
+		Field:   &ls.OtherConfig,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   []string{"vlan-passthru"},
+	}}
+	if enabled {
+		mutations = append(mutations, model.Mutation{
+			Field:   &ls.OtherConfig,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   map[string]string{"vlan-passthru": "true"},
+		})
+	}
+	ops, err := c.nbClient.Where(ls).Mutate(ls, mutations...)
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical switch %s mutation: %v", lsName, err)
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set vlan-passthru=%t on logicalswitch %s", enabled, lsName)
+	return nil
+}
+
+// ReconcileLogicalSwitchVLANPassthru turns vlan-passthru on for the switch
+// while one of its ports carries tagged frames, and back off once none does.
+func (c *Client) ReconcileLogicalSwitchVLANPassthru(lsName string) error {
+	ctx := context.Background()
+
+	ls, err := c.getLogicalSwitch(ctx, lsName)
+	if err != nil {
+		return err
+	}
+	enabled := false
+	for _, u := range ls.Ports {
+		lsp := &models.LogicalSwitchPort{UUID: u}
+		if err := c.nbClient.Get(ctx, lsp); err != nil {
+			return fmt.Errorf("failed to find logical switch port %s: %v", u, err)
+		}
+		if lsp.Options["vlan-passthru"] == "true" {
+			enabled = true
+			break
+		}
+	}
+	return c.SetLogicalSwitchVLANPassthru(lsName, enabled)
+}
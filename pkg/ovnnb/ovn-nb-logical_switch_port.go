@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 
 	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
 	"github.com/google/uuid"
@@ -11,8 +13,28 @@ import (
 	"github.com/ovn-kubernetes/libovsdb/ovsdb"
 )
 
+// External ids recorded on logical switch ports created for pods, so that
+// controllers can map ports back to their workloads.
+const (
+	ExternalIDNamespace = "ovn.ik8s.ir/namespace"
+	ExternalIDPod       = "ovn.ik8s.ir/pod"
+	ExternalIDVM        = "ovn.ik8s.ir/vm"
+	ExternalIDIP        = "ovn.ik8s.ir/ip"
+	// ExternalIDInterface is the interface name inside the pod, e.g. "net1".
+	ExternalIDInterface = "ovn.ik8s.ir/interface"
+	// ExternalIDBandwidth holds the JSON limits handed over by the bandwidth
+	// capability at ADD, which take precedence over the pod annotations.
+	ExternalIDBandwidth = "ovn.ik8s.ir/bandwidth"
+)
+
+// External ids recording the Kubernetes object that owns a row.
+const (
+	ExternalIDOwnerType = "ovn.ik8s.ir/owner-type"
+	ExternalIDOwner     = "ovn.ik8s.ir/owner"
+)
+
 // CreateLogicalPort creates a new logical port and attaches it to a logical switch
-func (c *Client) CreateLogicalPort(lsName, lspName, hostMAC string) error {
+func (c *Client) CreateLogicalPort(lsName, lspName, hostMAC string, externalIDs map[string]string) error {
 	ctx := context.Background()
 	lspUUID := uuid.New().String()
 	ls := &models.LogicalSwitch{Name: lsName}
@@ -27,13 +49,10 @@ func (c *Client) CreateLogicalPort(lsName, lspName, hostMAC string) error {
 	}
 
 	lsp := &models.LogicalSwitchPort{
-		UUID:      lspUUID,
-		Name:      lspName,
-		Addresses: []string{hostMAC},
-		// ExternalIDs: map[string]string{
-		// 	"iface-id": "pod1",
-		// 	"pod":      "true",
-		// },
+		UUID:        lspUUID,
+		Name:        lspName,
+		Addresses:   []string{hostMAC},
+		ExternalIDs: externalIDs,
 	}
 	lspOp, err := c.nbClient.Create(lsp)
 	if err != nil {
@@ -51,6 +70,13 @@ func (c *Client) CreateLogicalPort(lsName, lspName, hostMAC string) error {
 		return fmt.Errorf("failed to prepare mutation: %v", err)
 	}
 	ops := append(lspOp, mutateOps...)
+	if namespace := externalIDs[ExternalIDNamespace]; namespace != "" {
+		nsOps, err := c.namespaceJoinOps(ctx, namespace, lsp.UUID, externalIDs[ExternalIDIP])
+		if err != nil {
+			return err
+		}
+		ops = append(ops, nsOps...)
+	}
 	reply, err := c.nbClient.Transact(ctx, ops...)
 	if err != nil {
 		return fmt.Errorf("transaction failed: %v", err)
@@ -114,8 +140,23 @@ func (c *Client) DeleteLogicalPort(lsName, lspName string) error {
 		return fmt.Errorf("failed to prepare logical switch port delete: %v", err)
 	}
 
-	// 5️⃣ Run both in one transaction
+	// 5️⃣ Drop the port from its namespace port group and address set, and
+	// its QoS rules from the switch
 	ops := append(mutateOps, delOps...)
+	qosOps, err := c.qosLeaveOps(ctx, &ls, lsp.Name)
+	if err != nil {
+		return err
+	}
+	ops = append(ops, qosOps...)
+	if namespace := lsp.ExternalIDs[ExternalIDNamespace]; namespace != "" {
+		nsOps, err := c.namespaceLeaveOps(ctx, namespace, lsp.UUID, lsp.ExternalIDs[ExternalIDIP])
+		if err != nil {
+			return err
+		}
+		ops = append(ops, nsOps...)
+	}
+
+	// 6️⃣ Run everything in one transaction
 	reply, err := c.nbClient.Transact(ctx, ops...)
 	if err != nil {
 		return fmt.Errorf("transaction failed: %v", err)
@@ -154,3 +195,208 @@ func (c *Client) ListLogicalPorts(ctx context.Context, lsName string) ([]string,
 
 	return lsObj[0].Ports, nil
 }
+
+// FindPodLogicalPort returns the logical switch port of a vm interface, or
+// nil when there is none. Ports created before the interface was recorded
+// are taken as "eth0".
+func (c *Client) FindPodLogicalPort(namespace, vmName, ifName string) (*models.LogicalSwitchPort, error) {
+	lsps, err := c.ListPodLogicalPorts()
+	if err != nil {
+		return nil, err
+	}
+	for _, lsp := range lsps {
+		portIf := lsp.ExternalIDs[ExternalIDInterface]
+		if portIf == "" {
+			portIf = "eth0"
+		}
+		if lsp.ExternalIDs[ExternalIDNamespace] == namespace && lsp.ExternalIDs[ExternalIDVM] == vmName && portIf == ifName {
+			return &lsp, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListPodLogicalPorts returns the logical switch ports created for pods.
+func (c *Client) ListPodLogicalPorts() ([]models.LogicalSwitchPort, error) {
+	results := []models.LogicalSwitchPort{}
+	err := c.nbClient.WhereCache(func(lsp *models.LogicalSwitchPort) bool {
+		return lsp.ExternalIDs[ExternalIDNamespace] != ""
+	}).List(context.Background(), &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logical switch port cache: %v", err)
+	}
+	return results, nil
+}
+
+// SetLogicalPortVLANPassthru marks the port as carrying VLAN tagged frames to
+// and from the guest. OVN only passes them when the switch has vlan-passthru
+// too, see SetLogicalSwitchVLANPassthru.
+func (c *Client) SetLogicalPortVLANPassthru(lspName string, enabled bool) error {
+	ctx := context.Background()
+
+	results := []models.LogicalSwitchPort{}
+	err := c.nbClient.WhereCache(func(lsp *models.LogicalSwitchPort) bool {
+		return lsp.Name == lspName
+	}).List(ctx, &results)
+	if err != nil {
+		return fmt.Errorf("failed to query logical switch port cache: %v", err)
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("logical switch port %q not found", lspName)
+	}
+	lsp := &results[0]
+	if (lsp.Options["vlan-passthru"] == "true") == enabled {
+		return nil
+	}
+	mutations := []model.Mutation{{
+		Field:   &lsp.Options,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   []string{"vlan-passthru"},
+	}}
+	if enabled {
+		mutations = append(mutations, model.Mutation{
+			Field:   &lsp.Options,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   map[string]string{"vlan-passthru": "true"},
+		})
+	}
+	ops, err := c.nbClient.Where(lsp).Mutate(lsp, mutations...)
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical port %s mutation: %v", lspName, err)
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set vlan-passthru=%t on logicalport %s", enabled, lspName)
+	return nil
+}
+
+// EnsureChildLogicalPort creates, or updates, a port nested in the parent
+// port: traffic of the parent vNIC tagged with vlan belongs to the child, so
+// containers inside a VM get logical ports of their own. addresses are the
+// "mac ip..." entries of the port, also used as its port security.
+func (c *Client) EnsureChildLogicalPort(lsName, lspName, parentName string, vlan int, addresses []string, externalIDs map[string]string) error {
+	ctx := context.Background()
+
+	ls, err := c.getLogicalSwitch(ctx, lsName)
+	if err != nil {
+		return err
+	}
+	results := []models.LogicalSwitchPort{}
+	err = c.nbClient.WhereCache(func(lsp *models.LogicalSwitchPort) bool {
+		return lsp.Name == lspName
+	}).List(ctx, &results)
+	if err != nil {
+		return fmt.Errorf("failed to query logical switch port cache: %v", err)
+	}
+
+	var ops []ovsdb.Operation
+	if len(results) == 0 {
+		lsp := &models.LogicalSwitchPort{
+			UUID:         uuid.New().String(),
+			Name:         lspName,
+			ParentName:   &parentName,
+			TagRequest:   &vlan,
+			Addresses:    addresses,
+			PortSecurity: addresses,
+			ExternalIDs:  externalIDs,
+		}
+		lspOps, err := c.nbClient.Create(lsp)
+		if err != nil {
+			return fmt.Errorf("failed to create logical port %s: %v", lspName, err)
+		}
+		mutateOps, err := c.nbClient.Where(ls).Mutate(ls, model.Mutation{
+			Field:   &ls.Ports,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   []string{lsp.UUID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare mutation: %v", err)
+		}
+		ops = append(lspOps, mutateOps...)
+		if namespace := externalIDs[ExternalIDNamespace]; namespace != "" {
+			nsOps, err := c.namespaceJoinOps(ctx, namespace, lsp.UUID, externalIDs[ExternalIDIP])
+			if err != nil {
+				return err
+			}
+			ops = append(ops, nsOps...)
+		}
+	} else {
+		lsp := &results[0]
+		if equalPtr(lsp.ParentName, &parentName) && equalPtr(lsp.TagRequest, &vlan) &&
+			slices.Equal(lsp.Addresses, addresses) && slices.Equal(lsp.PortSecurity, addresses) &&
+			mapsEqual(lsp.ExternalIDs, externalIDs) {
+			return nil
+		}
+		// Move the port, and its IP, over to its current namespace and IP.
+		oldNamespace, oldIP := lsp.ExternalIDs[ExternalIDNamespace], lsp.ExternalIDs[ExternalIDIP]
+		newNamespace, newIP := externalIDs[ExternalIDNamespace], externalIDs[ExternalIDIP]
+		if oldNamespace != newNamespace || oldIP != newIP {
+			if oldNamespace != "" {
+				leaveOps, err := c.namespaceLeaveOps(ctx, oldNamespace, lsp.UUID, oldIP)
+				if err != nil {
+					return err
+				}
+				ops = append(ops, leaveOps...)
+			}
+			if newNamespace != "" {
+				joinOps, err := c.namespaceJoinOps(ctx, newNamespace, lsp.UUID, newIP)
+				if err != nil {
+					return err
+				}
+				ops = append(ops, joinOps...)
+			}
+		}
+		lsp.ParentName = &parentName
+		lsp.TagRequest = &vlan
+		lsp.Addresses = addresses
+		lsp.PortSecurity = addresses
+		lsp.ExternalIDs = externalIDs
+		updateOps, err := c.nbClient.Where(lsp).Update(lsp, &lsp.ParentName, &lsp.TagRequest, &lsp.Addresses, &lsp.PortSecurity, &lsp.ExternalIDs)
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical port %s update: %v", lspName, err)
+		}
+		ops = append(ops, updateOps...)
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set child logicalport %s of %s on vlan %d", lspName, parentName, vlan)
+	return nil
+}
+
+// ListChildLogicalPorts returns the ports nested in other ports.
+func (c *Client) ListChildLogicalPorts() ([]models.LogicalSwitchPort, error) {
+	results := []models.LogicalSwitchPort{}
+	err := c.nbClient.WhereCache(func(lsp *models.LogicalSwitchPort) bool {
+		return lsp.ParentName != nil
+	}).List(context.Background(), &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logical switch port cache: %v", err)
+	}
+	return results, nil
+}
+
+// ListLogicalPortsWithAddress returns the logical switch ports holding the
+// MAC or IP address, in their addresses or as their recorded pod IP.
+func (c *Client) ListLogicalPortsWithAddress(address string) ([]models.LogicalSwitchPort, error) {
+	results := []models.LogicalSwitchPort{}
+	err := c.nbClient.WhereCache(func(lsp *models.LogicalSwitchPort) bool {
+		if lsp.ExternalIDs[ExternalIDIP] == address {
+			return true
+		}
+		for _, entry := range lsp.Addresses {
+			for _, field := range strings.Fields(entry) {
+				if strings.EqualFold(field, address) {
+					return true
+				}
+			}
+		}
+		return false
+	}).List(context.Background(), &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query logical switch port cache: %v", err)
+	}
+	return results, nil
+}
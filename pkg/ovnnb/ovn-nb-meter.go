@@ -0,0 +1,96 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// EnsureMeter creates or updates a meter with a single drop band. unit is
+// models.MeterUnitPktps or models.MeterUnitKbps.
+func (c *Client) EnsureMeter(name, unit string, rate, burst int) error {
+	ctx := context.Background()
+
+	meters := []models.Meter{}
+	err := c.nbClient.WhereCache(func(m *models.Meter) bool {
+		return m.Name == name
+	}).List(ctx, &meters)
+	if err != nil {
+		return fmt.Errorf("failed to query meter cache: %v", err)
+	}
+
+	if len(meters) > 0 {
+		meter := &meters[0]
+		if meter.Unit == unit && len(meter.Bands) == 1 {
+			band := &models.MeterBand{UUID: meter.Bands[0]}
+			if err := c.nbClient.Get(ctx, band); err == nil && band.Rate == rate && band.BurstSize == burst {
+				return nil
+			}
+		}
+	}
+
+	// Meter_Band is not a root table: replaced bands are garbage collected.
+	band := &models.MeterBand{
+		UUID:      uuid.New().String(),
+		Action:    models.MeterBandActionDrop,
+		Rate:      rate,
+		BurstSize: burst,
+	}
+	ops, err := c.nbClient.Create(band)
+	if err != nil {
+		return fmt.Errorf("failed to create meter band: %v", err)
+	}
+
+	var meterOps []ovsdb.Operation
+	if len(meters) == 0 {
+		meterOps, err = c.nbClient.Create(&models.Meter{
+			UUID:  uuid.New().String(),
+			Name:  name,
+			Unit:  unit,
+			Bands: []string{band.UUID},
+		})
+	} else {
+		meter := &meters[0]
+		meter.Unit = unit
+		meter.Bands = []string{band.UUID}
+		meterOps, err = c.nbClient.Where(meter).Update(meter, &meter.Unit, &meter.Bands)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prepare meter %s: %v", name, err)
+	}
+
+	if err := c.transact(ctx, append(ops, meterOps...)...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set meter %s to %d %s (burst %d)", name, rate, unit, burst)
+	return nil
+}
+
+// DeleteMeter deletes the meter and its bands.
+func (c *Client) DeleteMeter(name string) error {
+	ctx := context.Background()
+
+	meters := []models.Meter{}
+	err := c.nbClient.WhereCache(func(m *models.Meter) bool {
+		return m.Name == name
+	}).List(ctx, &meters)
+	if err != nil {
+		return fmt.Errorf("failed to query meter cache: %v", err)
+	}
+	if len(meters) == 0 {
+		return nil
+	}
+	ops, err := c.nbClient.Where(&meters[0]).Delete()
+	if err != nil {
+		return fmt.Errorf("failed to prepare meter delete: %v", err)
+	}
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted meter %s", name)
+	return nil
+}
@@ -0,0 +1,128 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// EnsureMirror creates the mirror, or updates the one with the same name, and
+// makes it the mirror rule of exactly the given logical switch ports.
+func (c *Client) EnsureMirror(mirror *models.Mirror, portUUIDs []string) error {
+	ctx := context.Background()
+
+	existing, err := c.getMirror(ctx, mirror.Name)
+	if err != nil {
+		return err
+	}
+
+	var ops []ovsdb.Operation
+	if existing == nil {
+		mirror.UUID = uuid.New().String()
+		ops, err = c.nbClient.Create(mirror)
+		if err != nil {
+			return fmt.Errorf("failed to create mirror %s: %v", mirror.Name, err)
+		}
+	} else {
+		mirror.UUID = existing.UUID
+		if existing.Type != mirror.Type || existing.Filter != mirror.Filter || existing.Sink != mirror.Sink ||
+			existing.Index != mirror.Index || !mapsEqual(existing.ExternalIDs, mirror.ExternalIDs) {
+			ops, err = c.nbClient.Where(mirror).Update(mirror, &mirror.Type, &mirror.Filter, &mirror.Sink, &mirror.Index, &mirror.ExternalIDs)
+			if err != nil {
+				return fmt.Errorf("failed to prepare mirror %s update: %v", mirror.Name, err)
+			}
+		}
+	}
+
+	// Mirror rules are weak references, so ports drop them on their own
+	// when the mirror is deleted.
+	lsps := []models.LogicalSwitchPort{}
+	err = c.nbClient.WhereCache(func(lsp *models.LogicalSwitchPort) bool {
+		return slices.Contains(portUUIDs, lsp.UUID) || slices.Contains(lsp.MirrorRules, mirror.UUID)
+	}).List(ctx, &lsps)
+	if err != nil {
+		return fmt.Errorf("failed to query logical switch port cache: %v", err)
+	}
+	for i := range lsps {
+		lsp := &lsps[i]
+		want, has := slices.Contains(portUUIDs, lsp.UUID), slices.Contains(lsp.MirrorRules, mirror.UUID)
+		if want == has {
+			continue
+		}
+		mutator := ovsdb.MutateOperationInsert
+		if has {
+			mutator = ovsdb.MutateOperationDelete
+		}
+		mutateOps, err := c.nbClient.Where(lsp).Mutate(lsp, model.Mutation{
+			Field:   &lsp.MirrorRules,
+			Mutator: mutator,
+			Value:   []string{mirror.UUID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical switch port mutation: %v", err)
+		}
+		ops = append(ops, mutateOps...)
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Synced mirror %s (%s to %s) on %d ports", mirror.Name, mirror.Type, mirror.Sink, len(portUUIDs))
+	return nil
+}
+
+func (c *Client) DeleteMirror(name string) error {
+	ctx := context.Background()
+
+	mirror, err := c.getMirror(ctx, name)
+	if err != nil {
+		return err
+	}
+	if mirror == nil {
+		return nil
+	}
+	delOps, err := c.nbClient.Where(mirror).Delete()
+	if err != nil {
+		return fmt.Errorf("failed to prepare mirror %s delete: %v", name, err)
+	}
+	if err := c.transact(ctx, delOps...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted mirror %s", name)
+	return nil
+}
+
+// ListMirrors returns the mirrors whose external_ids[key] equals value.
+func (c *Client) ListMirrors(key, value string) ([]models.Mirror, error) {
+	results := []models.Mirror{}
+	err := c.nbClient.WhereCache(func(m *models.Mirror) bool {
+		return m.ExternalIDs[key] == value
+	}).List(context.Background(), &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mirror cache: %v", err)
+	}
+	return results, nil
+}
+
+func (c *Client) getMirror(ctx context.Context, name string) (*models.Mirror, error) {
+	results := []models.Mirror{}
+	err := c.nbClient.WhereCache(func(m *models.Mirror) bool {
+		return m.Name == name
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mirror cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
@@ -0,0 +1,152 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// NamespacePortGroupName returns the port group holding every pod port of a
+// namespace, usable in ACL matches as @ns_<namespace>. Namespace names never
+// contain '_', so mapping '-' to '_' is collision free.
+func NamespacePortGroupName(namespace string) string {
+	return "ns_" + strings.ReplaceAll(namespace, "-", "_")
+}
+
+// NamespaceAddressSetName returns the address set holding every pod IP of a
+// namespace, usable in ACL matches as $ns_<namespace>.
+func NamespaceAddressSetName(namespace string) string {
+	return NamespacePortGroupName(namespace)
+}
+
+// EnsureNamespacePortGroup creates the namespace port group if no pod of the
+// namespace has been attached yet, so that ACLs can be set on it in advance.
+func (c *Client) EnsureNamespacePortGroup(namespace string) error {
+	ctx := context.Background()
+	pg, err := c.getPortGroup(ctx, NamespacePortGroupName(namespace))
+	if err != nil || pg != nil {
+		return err
+	}
+	ops, err := c.nbClient.Create(&models.PortGroup{
+		UUID:        uuid.New().String(),
+		Name:        NamespacePortGroupName(namespace),
+		ExternalIDs: map[string]string{ExternalIDNamespace: namespace},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create namespace port group: %v", err)
+	}
+	return c.transact(ctx, ops...)
+}
+
+// namespaceJoinOps returns the operations adding a port and its IP to the
+// namespace port group and address set, creating them on first use.
+func (c *Client) namespaceJoinOps(ctx context.Context, namespace, lspUUID, ip string) ([]ovsdb.Operation, error) {
+	ids := map[string]string{ExternalIDNamespace: namespace}
+	ops := []ovsdb.Operation{}
+
+	pg, err := c.getPortGroup(ctx, NamespacePortGroupName(namespace))
+	if err != nil {
+		return nil, err
+	}
+	if pg == nil {
+		pgOps, err := c.nbClient.Create(&models.PortGroup{
+			UUID:        uuid.New().String(),
+			Name:        NamespacePortGroupName(namespace),
+			ExternalIDs: ids,
+			Ports:       []string{lspUUID},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create namespace port group: %v", err)
+		}
+		ops = append(ops, pgOps...)
+	} else {
+		pgOps, err := c.nbClient.Where(pg).Mutate(pg, model.Mutation{
+			Field:   &pg.Ports,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   []string{lspUUID},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare namespace port group mutation: %v", err)
+		}
+		ops = append(ops, pgOps...)
+	}
+
+	if ip == "" {
+		return ops, nil
+	}
+	as, err := c.getAddressSet(ctx, NamespaceAddressSetName(namespace))
+	if err != nil {
+		return nil, err
+	}
+	if as == nil {
+		asOps, err := c.nbClient.Create(&models.AddressSet{
+			UUID:        uuid.New().String(),
+			Name:        NamespaceAddressSetName(namespace),
+			ExternalIDs: ids,
+			Addresses:   []string{ip},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create namespace address set: %v", err)
+		}
+		ops = append(ops, asOps...)
+	} else {
+		asOps, err := c.nbClient.Where(as).Mutate(as, model.Mutation{
+			Field:   &as.Addresses,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   []string{ip},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare namespace address set mutation: %v", err)
+		}
+		ops = append(ops, asOps...)
+	}
+	return ops, nil
+}
+
+// namespaceLeaveOps returns the operations removing a port and its IP from the
+// namespace port group and address set. The groups themselves are kept since
+// operator ACLs may still reference them.
+func (c *Client) namespaceLeaveOps(ctx context.Context, namespace, lspUUID, ip string) ([]ovsdb.Operation, error) {
+	ops := []ovsdb.Operation{}
+
+	pg, err := c.getPortGroup(ctx, NamespacePortGroupName(namespace))
+	if err != nil {
+		return nil, err
+	}
+	if pg != nil {
+		pgOps, err := c.nbClient.Where(pg).Mutate(pg, model.Mutation{
+			Field:   &pg.Ports,
+			Mutator: ovsdb.MutateOperationDelete,
+			Value:   []string{lspUUID},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare namespace port group mutation: %v", err)
+		}
+		ops = append(ops, pgOps...)
+	}
+
+	if ip == "" {
+		return ops, nil
+	}
+	as, err := c.getAddressSet(ctx, NamespaceAddressSetName(namespace))
+	if err != nil {
+		return nil, err
+	}
+	if as != nil {
+		asOps, err := c.nbClient.Where(as).Mutate(as, model.Mutation{
+			Field:   &as.Addresses,
+			Mutator: ovsdb.MutateOperationDelete,
+			Value:   []string{ip},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare namespace address set mutation: %v", err)
+		}
+		ops = append(ops, asOps...)
+	}
+	return ops, nil
+}
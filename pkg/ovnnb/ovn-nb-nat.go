@@ -0,0 +1,140 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// AddOrUpdateNAT creates the NAT rule on the logical router, or updates the
+// rule with the same type and external IP. A rule owned by another object is
+// left alone.
+func (c *Client) AddOrUpdateNAT(lrName string, nat *models.NAT) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	existing, err := c.findNAT(ctx, lr, nat.Type, nat.ExternalIP)
+	if err != nil {
+		return err
+	}
+
+	var ops []ovsdb.Operation
+	if existing == nil {
+		// NAT is not a root table, so create and reference it together.
+		nat.UUID = uuid.New().String()
+		natOps, err := c.nbClient.Create(nat)
+		if err != nil {
+			return fmt.Errorf("failed to create nat %s: %v", nat.ExternalIP, err)
+		}
+		mutateOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+			Field:   &lr.Nat,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   []string{nat.UUID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+		}
+		ops = append(natOps, mutateOps...)
+	} else {
+		if owner := existing.ExternalIDs[ExternalIDOwner]; owner != "" && owner != nat.ExternalIDs[ExternalIDOwner] {
+			log.Printf("⚠️ %s %s on router %s is owned by %s, not updating it for %s",
+				nat.Type, nat.ExternalIP, lrName, owner, nat.ExternalIDs[ExternalIDOwner])
+			return nil
+		}
+		if existing.LogicalIP == nat.LogicalIP && equalPtr(existing.LogicalPort, nat.LogicalPort) &&
+			equalPtr(existing.ExternalMAC, nat.ExternalMAC) && mapsEqual(existing.ExternalIDs, nat.ExternalIDs) &&
+			mapsEqual(existing.Options, nat.Options) {
+			return nil
+		}
+		nat.UUID = existing.UUID
+		ops, err = c.nbClient.Where(nat).Update(nat, &nat.LogicalIP, &nat.LogicalPort, &nat.ExternalMAC, &nat.ExternalIDs, &nat.Options)
+		if err != nil {
+			return fmt.Errorf("failed to prepare nat %s update: %v", nat.ExternalIP, err)
+		}
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set %s %s -> %s on router %s", nat.Type, nat.ExternalIP, nat.LogicalIP, lrName)
+	return nil
+}
+
+// DeleteNAT removes the NAT rule with the given type and external IP.
+func (c *Client) DeleteNAT(lrName, natType, externalIP string) error {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return err
+	}
+	nat, err := c.findNAT(ctx, lr, natType, externalIP)
+	if err != nil {
+		return err
+	}
+	if nat == nil {
+		log.Printf("⚠️ %s %s not found on router %s, skipping delete", natType, externalIP, lrName)
+		return nil
+	}
+
+	mutateOps, err := c.nbClient.Where(lr).Mutate(lr, model.Mutation{
+		Field:   &lr.Nat,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   []string{nat.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare logical router mutation: %v", err)
+	}
+	if err := c.transact(ctx, mutateOps...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted %s %s from router %s", natType, externalIP, lrName)
+	return nil
+}
+
+// ListNATs returns the NAT rules of a logical router.
+func (c *Client) ListNATs(lrName string) ([]models.NAT, error) {
+	ctx := context.Background()
+
+	lr, err := c.getLogicalRouter(ctx, lrName)
+	if err != nil {
+		return nil, err
+	}
+	nats := []models.NAT{}
+	for _, u := range lr.Nat {
+		nat := models.NAT{UUID: u}
+		if err := c.nbClient.Get(ctx, &nat); err != nil {
+			return nil, fmt.Errorf("failed to find nat %s: %v", u, err)
+		}
+		nats = append(nats, nat)
+	}
+	return nats, nil
+}
+
+func (c *Client) findNAT(ctx context.Context, lr *models.LogicalRouter, natType, externalIP string) (*models.NAT, error) {
+	for _, u := range lr.Nat {
+		nat := &models.NAT{UUID: u}
+		if err := c.nbClient.Get(ctx, nat); err != nil {
+			return nil, fmt.Errorf("failed to find nat %s: %v", u, err)
+		}
+		if nat.Type == natType && nat.ExternalIP == externalIP {
+			return nat, nil
+		}
+	}
+	return nil, nil
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
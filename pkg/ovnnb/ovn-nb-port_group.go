@@ -0,0 +1,186 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// EnsurePortGroup creates the port group if it does not exist and sets its
+// member logical switch ports (by UUID) and external_ids.
+func (c *Client) EnsurePortGroup(name string, externalIDs map[string]string, portUUIDs []string) error {
+	ctx := context.Background()
+	if portUUIDs == nil {
+		portUUIDs = []string{}
+	}
+
+	pg, err := c.getPortGroup(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	var ops []ovsdb.Operation
+	if pg == nil {
+		pg = &models.PortGroup{
+			UUID:        uuid.New().String(),
+			Name:        name,
+			ExternalIDs: externalIDs,
+			Ports:       portUUIDs,
+		}
+		ops, err = c.nbClient.Create(pg)
+		if err != nil {
+			return fmt.Errorf("failed to create port group %s: %v", name, err)
+		}
+	} else {
+		if sameSet(pg.Ports, portUUIDs) && mapsEqual(pg.ExternalIDs, externalIDs) {
+			return nil
+		}
+		pg.Ports = portUUIDs
+		pg.ExternalIDs = externalIDs
+		ops, err = c.nbClient.Where(pg).Update(pg, &pg.Ports, &pg.ExternalIDs)
+		if err != nil {
+			return fmt.Errorf("failed to prepare port group %s update: %v", name, err)
+		}
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Synced port group %s (%d ports)", name, len(portUUIDs))
+	return nil
+}
+
+// SetPortGroupACLs replaces the ACLs of a port group. ACL is not a root table,
+// so the previous rows are garbage collected once they are unreferenced.
+func (c *Client) SetPortGroupACLs(name string, acls []*models.ACL) error {
+	return c.ReplacePortGroupACLs(name, func(*models.ACL) bool { return true }, acls)
+}
+
+// ReplacePortGroupACLs replaces the ACLs of a port group for which owned
+// returns true, keeping the others. This lets several owners share a group.
+func (c *Client) ReplacePortGroupACLs(name string, owned func(*models.ACL) bool, acls []*models.ACL) error {
+	ctx := context.Background()
+
+	pg, err := c.getPortGroup(ctx, name)
+	if err != nil {
+		return err
+	}
+	if pg == nil {
+		return fmt.Errorf("port group %q not found", name)
+	}
+
+	current, err := c.getACLs(ctx, pg.ACLs)
+	if err != nil {
+		return err
+	}
+	kept := []string{}
+	replaced := []*models.ACL{}
+	for _, acl := range current {
+		if owned(acl) {
+			replaced = append(replaced, acl)
+		} else {
+			kept = append(kept, acl.UUID)
+		}
+	}
+	if sameACLs(replaced, acls) {
+		return nil
+	}
+
+	ops := []ovsdb.Operation{}
+	pg.ACLs = kept
+	for _, acl := range acls {
+		acl.UUID = uuid.New().String()
+		aclOp, err := c.nbClient.Create(acl)
+		if err != nil {
+			return fmt.Errorf("failed to create acl: %v", err)
+		}
+		ops = append(ops, aclOp...)
+		pg.ACLs = append(pg.ACLs, acl.UUID)
+	}
+	updateOps, err := c.nbClient.Where(pg).Update(pg, &pg.ACLs)
+	if err != nil {
+		return fmt.Errorf("failed to prepare port group %s update: %v", name, err)
+	}
+	ops = append(ops, updateOps...)
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Set %d acls on port group %s", len(acls), name)
+	return nil
+}
+
+// DeletePortGroup deletes the port group and, with it, its ACLs.
+func (c *Client) DeletePortGroup(name string) error {
+	ctx := context.Background()
+
+	pg, err := c.getPortGroup(ctx, name)
+	if err != nil {
+		return err
+	}
+	if pg == nil {
+		return nil
+	}
+	delOps, err := c.nbClient.Where(pg).Delete()
+	if err != nil {
+		return fmt.Errorf("failed to prepare port group %s delete: %v", name, err)
+	}
+	if err := c.transact(ctx, delOps...); err != nil {
+		return err
+	}
+	log.Printf("🧹 Deleted port group %s", name)
+	return nil
+}
+
+// ListPortGroups returns the port groups whose external_ids[key] equals value.
+func (c *Client) ListPortGroups(key, value string) ([]models.PortGroup, error) {
+	results := []models.PortGroup{}
+	err := c.nbClient.WhereCache(func(pg *models.PortGroup) bool {
+		return pg.ExternalIDs[key] == value
+	}).List(context.Background(), &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query port group cache: %v", err)
+	}
+	return results, nil
+}
+
+func (c *Client) getPortGroup(ctx context.Context, name string) (*models.PortGroup, error) {
+	results := []models.PortGroup{}
+	err := c.nbClient.WhereCache(func(pg *models.PortGroup) bool {
+		return pg.Name == name
+	}).List(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query port group cache: %v", err)
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &results[0], nil
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func mapsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
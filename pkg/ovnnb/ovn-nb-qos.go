@@ -0,0 +1,179 @@
+package ovnnb
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// External ids recorded on the QoS rules of a logical switch port, so that
+// each feature replaces only its own rules.
+const (
+	ExternalIDQoSPort = "ovn.ik8s.ir/qos-port"
+	ExternalIDQoSKind = "ovn.ik8s.ir/qos-kind"
+)
+
+// QoSKindBandwidth marks the rules limiting the traffic towards a port.
+const QoSKindBandwidth = "bandwidth"
+
+// SetLogicalPortQoS replaces the QoS rules of the given kind attached to a
+// logical switch port. An empty rules list removes them.
+func (c *Client) SetLogicalPortQoS(lsName, lspName, kind string, rules []*models.QoS) error {
+	for _, r := range rules {
+		r.ExternalIDs = map[string]string{ExternalIDQoSPort: lspName, ExternalIDQoSKind: kind}
+	}
+	return c.ReplaceSwitchQoS(lsName, func(r *models.QoS) bool {
+		return r.ExternalIDs[ExternalIDQoSPort] == lspName && r.ExternalIDs[ExternalIDQoSKind] == kind
+	}, rules)
+}
+
+// ReplaceSwitchQoS replaces the QoS rules of a logical switch for which owned
+// returns true with rules, leaving the other rules untouched.
+func (c *Client) ReplaceSwitchQoS(lsName string, owned func(*models.QoS) bool, rules []*models.QoS) error {
+	ctx := context.Background()
+
+	ls, err := c.getLogicalSwitch(ctx, lsName)
+	if err != nil {
+		return err
+	}
+	current := []*models.QoS{}
+	for _, u := range ls.QOSRules {
+		r := &models.QoS{UUID: u}
+		if err := c.nbClient.Get(ctx, r); err != nil {
+			return fmt.Errorf("failed to find qos %s: %v", u, err)
+		}
+		if owned(r) {
+			current = append(current, r)
+		}
+	}
+	if sameQoS(current, rules) {
+		return nil
+	}
+
+	// QoS is not a root table, so new rules are created and referenced
+	// together and the old ones are collected once unreferenced.
+	var ops []ovsdb.Operation
+	uuids := []string{}
+	for _, r := range rules {
+		r.UUID = uuid.New().String()
+		qosOps, err := c.nbClient.Create(r)
+		if err != nil {
+			return fmt.Errorf("failed to create qos %q: %v", r.Match, err)
+		}
+		ops = append(ops, qosOps...)
+		uuids = append(uuids, r.UUID)
+	}
+	if len(uuids) > 0 {
+		mutateOps, err := c.nbClient.Where(ls).Mutate(ls, model.Mutation{
+			Field:   &ls.QOSRules,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   uuids,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical switch mutation: %v", err)
+		}
+		ops = append(ops, mutateOps...)
+	}
+	if len(current) > 0 {
+		stale := []string{}
+		for _, r := range current {
+			stale = append(stale, r.UUID)
+		}
+		mutateOps, err := c.nbClient.Where(ls).Mutate(ls, model.Mutation{
+			Field:   &ls.QOSRules,
+			Mutator: ovsdb.MutateOperationDelete,
+			Value:   stale,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare logical switch mutation: %v", err)
+		}
+		ops = append(ops, mutateOps...)
+	}
+
+	if err := c.transact(ctx, ops...); err != nil {
+		return err
+	}
+	log.Printf("✅ Synced %d qos rules on logicalswitch %s", len(rules), lsName)
+	return nil
+}
+
+// SetLogicalPortBandwidth limits the traffic sent to a logical switch port,
+// in kbps with a burst in kbits. A zero rate removes the limit.
+func (c *Client) SetLogicalPortBandwidth(lsName, lspName string, rateKbps, burstKb int) error {
+	rules := []*models.QoS{}
+	if rateKbps > 0 {
+		bandwidth := map[string]int{"rate": rateKbps}
+		if burstKb > 0 {
+			bandwidth["burst"] = burstKb
+		}
+		rules = append(rules, &models.QoS{
+			Direction: models.QoSDirectionToLport,
+			Match:     fmt.Sprintf("outport == %q", lspName),
+			Priority:  1000,
+			Bandwidth: bandwidth,
+		})
+	}
+	return c.SetLogicalPortQoS(lsName, lspName, QoSKindBandwidth, rules)
+}
+
+// qosLeaveOps drops every QoS rule of a logical switch port from its switch.
+func (c *Client) qosLeaveOps(ctx context.Context, ls *models.LogicalSwitch, lspName string) ([]ovsdb.Operation, error) {
+	rules, err := c.getPortQoS(ctx, ls, lspName)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	uuids := []string{}
+	for _, r := range rules {
+		uuids = append(uuids, r.UUID)
+	}
+	mutateOps, err := c.nbClient.Where(ls).Mutate(ls, model.Mutation{
+		Field:   &ls.QOSRules,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   uuids,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare logical switch mutation: %v", err)
+	}
+	return mutateOps, nil
+}
+
+// getPortQoS returns the QoS rules of a port on the switch.
+func (c *Client) getPortQoS(ctx context.Context, ls *models.LogicalSwitch, lspName string) ([]*models.QoS, error) {
+	rules := []*models.QoS{}
+	for _, u := range ls.QOSRules {
+		r := &models.QoS{UUID: u}
+		if err := c.nbClient.Get(ctx, r); err != nil {
+			return nil, fmt.Errorf("failed to find qos %s: %v", u, err)
+		}
+		if r.ExternalIDs[ExternalIDQoSPort] != lspName {
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func sameQoS(a, b []*models.QoS) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	keys := map[string]bool{}
+	for _, r := range a {
+		keys[qosKey(r)] = true
+	}
+	for _, r := range b {
+		if !keys[qosKey(r)] {
+			return false
+		}
+	}
+	return true
+}
+
+func qosKey(r *models.QoS) string {
+	return fmt.Sprintf("%s|%d|%s|%v|%v|%v", r.Direction, r.Priority, r.Match, r.Action, r.Bandwidth, r.ExternalIDs)
+}
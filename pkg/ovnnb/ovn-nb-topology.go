@@ -0,0 +1,129 @@
+package ovnnb
+
+import (
+	"fmt"
+	"net"
+
+	models "github.com/cybercoder/ik8s-ovn-cni/pkg/ovnnb/models"
+)
+
+// ExternalIDTenant marks the switches and routers built for a tenant.
+const ExternalIDTenant = "ovn.ik8s.ir/tenant"
+
+// GatewayTopology describes a tenant network: a switch for the workloads, a
+// router holding RouterIP on it, and a gateway port with ExternalIP on the
+// shared external switch. The gateway port is pinned to GatewayChassis, in
+// descending priority order, and the tenant subnet is SNATed behind it.
+type GatewayTopology struct {
+	Tenant          string
+	RouterIP        string // CIDR, e.g. 10.10.0.1/24
+	ExternalSwitch  string
+	ExternalIP      string // CIDR, e.g. 203.0.113.10/24
+	ExternalGateway string
+	GatewayChassis  []string
+	// Copp is the control plane protection policy of the tenant switch and
+	// router, none when empty.
+	Copp string
+}
+
+// TenantSwitchName returns the name of the tenant logical switch.
+func TenantSwitchName(tenant string) string { return "ls-" + tenant }
+
+// TenantRouterName returns the name of the tenant logical router.
+func TenantRouterName(tenant string) string { return "lr-" + tenant }
+
+func tenantInternalPort(tenant string) string { return "lrp-" + tenant }
+func tenantGatewayPort(tenant string) string  { return "lrp-" + tenant + "-gw" }
+
+// EnsureGatewayTopology creates the tenant switch, router and external
+// gateway described by t, updating whatever already exists.
+func (c *Client) EnsureGatewayTopology(t GatewayTopology) error {
+	routerIP, subnet, err := net.ParseCIDR(t.RouterIP)
+	if err != nil {
+		return fmt.Errorf("invalid router ip %q: %v", t.RouterIP, err)
+	}
+	externalIP, _, err := net.ParseCIDR(t.ExternalIP)
+	if err != nil {
+		return fmt.Errorf("invalid external ip %q: %v", t.ExternalIP, err)
+	}
+	ids := map[string]string{ExternalIDTenant: t.Tenant}
+	lsName := TenantSwitchName(t.Tenant)
+	lrName := TenantRouterName(t.Tenant)
+	internalPort := tenantInternalPort(t.Tenant)
+	gatewayPort := tenantGatewayPort(t.Tenant)
+
+	// 1️⃣ Tenant switch and router
+	if err := c.CreateLogicalSwitch(lsName, ids); err != nil {
+		return err
+	}
+	if err := c.CreateLogicalRouter(lrName, nil, ids); err != nil {
+		return err
+	}
+	if err := c.SetLogicalSwitchCopp(lsName, t.Copp); err != nil {
+		return err
+	}
+	if err := c.SetLogicalRouterCopp(lrName, t.Copp); err != nil {
+		return err
+	}
+
+	// 2️⃣ Connect the router to the tenant switch
+	if err := c.CreateLogicalRouterPort(lrName, internalPort, routerPortMAC(routerIP), []string{t.RouterIP}); err != nil {
+		return err
+	}
+	if err := c.CreateRouterTypePort(lsName, internalPort+"-peer", internalPort); err != nil {
+		return err
+	}
+
+	// 3️⃣ Connect the router to the external switch through a gateway port
+	if err := c.CreateLogicalRouterPort(lrName, gatewayPort, routerPortMAC(externalIP), []string{t.ExternalIP}); err != nil {
+		return err
+	}
+	if err := c.CreateRouterTypePort(t.ExternalSwitch, gatewayPort+"-peer", gatewayPort); err != nil {
+		return err
+	}
+	chassis := map[string]int{}
+	for i, name := range t.GatewayChassis {
+		chassis[name] = len(t.GatewayChassis) - i
+	}
+	if err := c.SetGatewayChassis(gatewayPort, chassis); err != nil {
+		return err
+	}
+
+	// 4️⃣ Default route and SNAT towards the external network
+	if t.ExternalGateway != "" {
+		if err := c.ReplaceStaticRoute(lrName, &models.LogicalRouterStaticRoute{
+			IPPrefix:    "0.0.0.0/0",
+			Nexthop:     t.ExternalGateway,
+			ExternalIDs: ids,
+		}); err != nil {
+			return err
+		}
+	}
+	return c.AddOrUpdateNAT(lrName, &models.NAT{
+		Type:        models.NATTypeSNAT,
+		ExternalIP:  externalIP.String(),
+		LogicalIP:   subnet.String(),
+		ExternalIDs: ids,
+	})
+}
+
+// DeleteGatewayTopology removes everything EnsureGatewayTopology created for
+// the tenant.
+func (c *Client) DeleteGatewayTopology(tenant, externalSwitch string) error {
+	if err := c.DeleteLogicalPort(externalSwitch, tenantGatewayPort(tenant)+"-peer"); err != nil {
+		return err
+	}
+	if err := c.DeleteLogicalRouter(TenantRouterName(tenant)); err != nil {
+		return err
+	}
+	return c.DeleteLogicalSwitch(TenantSwitchName(tenant))
+}
+
+// routerPortMAC derives a stable, locally administered MAC from an IPv4
+// address, so router ports keep their MAC when recreated.
+func routerPortMAC(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("0a:58:%02x:%02x:%02x:%02x", ip4[0], ip4[1], ip4[2], ip4[3])
+	}
+	return fmt.Sprintf("0a:58:%02x:%02x:%02x:%02x", ip[12], ip[13], ip[14], ip[15])
+}
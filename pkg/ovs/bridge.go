@@ -0,0 +1,171 @@
+package ovs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	ovsModel "github.com/cybercoder/ik8s-ovn-cni/pkg/ovs/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// bridgeMappingsKey is the Open_vSwitch external_ids key ovn-controller reads
+// the provider network to bridge mappings from.
+const bridgeMappingsKey = "ovn-bridge-mappings"
+
+// EnsureBridge creates the bridge with its internal port, as ovs-vsctl
+// add-br does, and plugs the uplink NIC into it. An empty uplink only
+// ensures the bridge.
+func (c *Client) EnsureBridge(bridgeName, uplink string) error {
+	ctx := context.Background()
+
+	var ops []ovsdb.Operation
+	bridge := &ovsModel.Bridge{Name: bridgeName}
+	if err := c.ovsClient.Get(ctx, bridge); err != nil {
+		roots := []ovsModel.OpenvSwitch{}
+		if err := c.ovsClient.List(ctx, &roots); err != nil {
+			return fmt.Errorf("failed to list Open_vSwitch: %v", err)
+		}
+		if len(roots) == 0 {
+			return fmt.Errorf("Open_vSwitch row not found")
+		}
+		root := &roots[0]
+
+		// Bridge, Port and Interface are not root tables, so create and
+		// reference them together.
+		portOps, portUUID, err := c.createPortOps(bridgeName, "internal")
+		if err != nil {
+			return err
+		}
+		bridge = &ovsModel.Bridge{
+			UUID:  uuid.New().String(),
+			Name:  bridgeName,
+			Ports: []string{portUUID},
+		}
+		bridgeOps, err := c.ovsClient.Create(bridge)
+		if err != nil {
+			return fmt.Errorf("failed to create bridge %s: %v", bridgeName, err)
+		}
+		rootOps, err := c.ovsClient.Where(root).Mutate(root, model.Mutation{
+			Field:   &root.Bridges,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   []string{bridge.UUID},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prepare Open_vSwitch mutation: %v", err)
+		}
+		ops = append(portOps, append(bridgeOps, rootOps...)...)
+	}
+
+	if uplink != "" {
+		port := &ovsModel.Port{Name: uplink}
+		if err := c.ovsClient.Get(ctx, port); err != nil {
+			portOps, portUUID, err := c.createPortOps(uplink, "")
+			if err != nil {
+				return err
+			}
+			mutateOps, err := c.ovsClient.Where(bridge).Mutate(bridge, model.Mutation{
+				Field:   &bridge.Ports,
+				Mutator: ovsdb.MutateOperationInsert,
+				Value:   []string{portUUID},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to prepare bridge mutation: %v", err)
+			}
+			ops = append(ops, append(portOps, mutateOps...)...)
+		}
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	reply, err := c.ovsClient.Transact(ctx, ops...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	log.Printf("✅ Ensured bridge %s with uplink %q", bridgeName, uplink)
+	return nil
+}
+
+// SetBridgeMapping maps the provider network to the bridge in
+// ovn-bridge-mappings, keeping the other mappings.
+func (c *Client) SetBridgeMapping(networkName, bridgeName string) error {
+	ctx := context.Background()
+
+	roots := []ovsModel.OpenvSwitch{}
+	if err := c.ovsClient.List(ctx, &roots); err != nil {
+		return fmt.Errorf("failed to list Open_vSwitch: %v", err)
+	}
+	if len(roots) == 0 {
+		return fmt.Errorf("Open_vSwitch row not found")
+	}
+	root := &roots[0]
+
+	mappings := map[string]string{}
+	for _, mapping := range strings.Split(root.ExternalIDs[bridgeMappingsKey], ",") {
+		if network, bridge, ok := strings.Cut(strings.TrimSpace(mapping), ":"); ok {
+			mappings[network] = bridge
+		}
+	}
+	if mappings[networkName] == bridgeName {
+		return nil
+	}
+	mappings[networkName] = bridgeName
+	items := make([]string, 0, len(mappings))
+	for network, bridge := range mappings {
+		items = append(items, network+":"+bridge)
+	}
+	sort.Strings(items)
+
+	ops, err := c.ovsClient.Where(root).Mutate(root,
+		model.Mutation{Field: &root.ExternalIDs, Mutator: ovsdb.MutateOperationDelete, Value: []string{bridgeMappingsKey}},
+		model.Mutation{Field: &root.ExternalIDs, Mutator: ovsdb.MutateOperationInsert, Value: map[string]string{bridgeMappingsKey: strings.Join(items, ",")}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to prepare Open_vSwitch mutation: %v", err)
+	}
+	reply, err := c.ovsClient.Transact(ctx, ops...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	log.Printf("✅ Mapped provider network %s to bridge %s", networkName, bridgeName)
+	return nil
+}
+
+// createPortOps returns the operations creating a port with a single
+// interface of the same name, and the port UUID.
+func (c *Client) createPortOps(name, ifaceType string) ([]ovsdb.Operation, string, error) {
+	iface := &ovsModel.Interface{
+		UUID: uuid.New().String(),
+		Name: name,
+		Type: ifaceType,
+	}
+	ifaceOps, err := c.ovsClient.Create(iface)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create interface %s: %v", name, err)
+	}
+	port := &ovsModel.Port{
+		UUID:       uuid.New().String(),
+		Name:       name,
+		Interfaces: []string{iface.UUID},
+	}
+	portOps, err := c.ovsClient.Create(port)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create port %s: %v", name, err)
+	}
+	return append(ifaceOps, portOps...), port.UUID, nil
+}
@@ -15,9 +15,20 @@ type Client struct {
 
 func CreateOVSclient() (*Client, error) {
 	dbModel, err := model.NewClientDBModel("Open_vSwitch", map[string]model.Model{
-		"Bridge":    &ovsModels.Bridge{},
-		"Port":      &ovsModels.Port{},
-		"Interface": &ovsModels.Interface{},
+		"Bridge":                    &ovsModels.Bridge{},
+		"Port":                      &ovsModels.Port{},
+		"Interface":                 &ovsModels.Interface{},
+		"QoS":                       &ovsModels.QoS{},
+		"Queue":                     &ovsModels.Queue{},
+		"Mirror":                    &ovsModels.Mirror{},
+		"sFlow":                     &ovsModels.SFlow{},
+		"IPFIX":                     &ovsModels.IPFIX{},
+		"NetFlow":                   &ovsModels.NetFlow{},
+		"Open_vSwitch":              &ovsModels.OpenvSwitch{},
+		"Datapath":                  &ovsModels.Datapath{},
+		"CT_Zone":                   &ovsModels.CTZone{},
+		"CT_Timeout_Policy":         &ovsModels.CTTimeoutPolicy{},
+		"Flow_Sample_Collector_Set": &ovsModels.FlowSampleCollectorSet{},
 	})
 	if err != nil {
 		log.Printf("failed to create DB model: %v", err)
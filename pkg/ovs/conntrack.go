@@ -0,0 +1,239 @@
+package ovs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	ovsModel "github.com/cybercoder/ik8s-ovn-cni/pkg/ovs/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// PortConntrackZone returns the conntrack zone ovn-controller assigned to the
+// logical port, recorded in the external_ids of the integration bridge.
+func (c *Client) PortConntrackZone(bridgeName, portName string) (int, bool, error) {
+	bridge := &ovsModel.Bridge{Name: bridgeName}
+	if err := c.ovsClient.Get(context.Background(), bridge); err != nil {
+		return 0, false, fmt.Errorf("failed to get bridge %q: %v", bridgeName, err)
+	}
+	value, ok := bridge.ExternalIDs["ct-zone-"+portName]
+	if !ok {
+		return 0, false, nil
+	}
+	zone, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid conntrack zone %q of port %s", value, portName)
+	}
+	return zone, true, nil
+}
+
+// SetConntrackZone limits the connections of a conntrack zone of the bridge
+// datapath and applies the timeouts, in seconds keyed by the CT_Timeout_Policy
+// names such as "tcp_established". The zone is tagged with the port it
+// belongs to so DelPort can release it. A zero limit and no timeouts remove
+// the zone configuration.
+func (c *Client) SetConntrackZone(bridgeName, portName string, zone, limit int, timeouts map[string]int) error {
+	ctx := context.Background()
+
+	bridge := &ovsModel.Bridge{Name: bridgeName}
+	if err := c.ovsClient.Get(ctx, bridge); err != nil {
+		return fmt.Errorf("failed to get bridge %q: %v", bridgeName, err)
+	}
+	dp, ops, err := c.ensureDatapath(ctx, bridge)
+	if err != nil {
+		return err
+	}
+
+	if u, ok := dp.CTZones[zone]; ok {
+		current := &ovsModel.CTZone{UUID: u}
+		if err := c.ovsClient.Get(ctx, current); err != nil {
+			return fmt.Errorf("failed to find conntrack zone %s: %v", u, err)
+		}
+		same, err := c.sameConntrackZone(ctx, current, portName, limit, timeouts)
+		if err != nil {
+			return err
+		}
+		if same {
+			return nil
+		}
+	} else if limit == 0 && len(timeouts) == 0 {
+		return nil
+	}
+
+	// CT_Zone and CT_Timeout_Policy are not root tables, so the replaced rows
+	// go away with the datapath reference.
+	mutations := []model.Mutation{{
+		Field:   &dp.CTZones,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   []int{zone},
+	}}
+	if limit > 0 || len(timeouts) > 0 {
+		ids := map[string]string{"iface-id": portName}
+		ctZone := &ovsModel.CTZone{
+			UUID:        uuid.New().String(),
+			Limit:       optionalInt(limit),
+			ExternalIDs: ids,
+		}
+		if len(timeouts) > 0 {
+			policy := &ovsModel.CTTimeoutPolicy{
+				UUID:        uuid.New().String(),
+				Timeouts:    timeouts,
+				ExternalIDs: ids,
+			}
+			policyOps, err := c.ovsClient.Create(policy)
+			if err != nil {
+				return fmt.Errorf("failed to create conntrack timeout policy: %v", err)
+			}
+			ops = append(ops, policyOps...)
+			ctZone.TimeoutPolicy = &policy.UUID
+		}
+		zoneOps, err := c.ovsClient.Create(ctZone)
+		if err != nil {
+			return fmt.Errorf("failed to create conntrack zone: %v", err)
+		}
+		ops = append(ops, zoneOps...)
+		mutations = append(mutations, model.Mutation{
+			Field:   &dp.CTZones,
+			Mutator: ovsdb.MutateOperationInsert,
+			Value:   map[int]string{zone: ctZone.UUID},
+		})
+	}
+	mutateOps, err := c.ovsClient.Where(dp).Mutate(dp, mutations...)
+	if err != nil {
+		return fmt.Errorf("failed to prepare datapath mutation: %v", err)
+	}
+	reply, err := c.ovsClient.Transact(ctx, append(ops, mutateOps...)...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	log.Printf("✅ Set conntrack zone %d of port %s: limit %d, timeouts %v", zone, portName, limit, timeouts)
+	return nil
+}
+
+// ensureDatapath returns the Datapath row of the bridge datapath type, with
+// the operations creating it when OVS has none yet.
+func (c *Client) ensureDatapath(ctx context.Context, bridge *ovsModel.Bridge) (*ovsModel.Datapath, []ovsdb.Operation, error) {
+	dpType := datapathType(bridge)
+
+	roots := []ovsModel.OpenvSwitch{}
+	if err := c.ovsClient.List(ctx, &roots); err != nil {
+		return nil, nil, fmt.Errorf("failed to list Open_vSwitch: %v", err)
+	}
+	if len(roots) == 0 {
+		return nil, nil, fmt.Errorf("Open_vSwitch row not found")
+	}
+	root := &roots[0]
+	if u, ok := root.Datapaths[dpType]; ok {
+		dp := &ovsModel.Datapath{UUID: u}
+		if err := c.ovsClient.Get(ctx, dp); err != nil {
+			return nil, nil, fmt.Errorf("failed to find datapath %s: %v", dpType, err)
+		}
+		return dp, nil, nil
+	}
+
+	// Datapath is not a root table, so create and reference it together.
+	dp := &ovsModel.Datapath{UUID: uuid.New().String()}
+	dpOps, err := c.ovsClient.Create(dp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create datapath %s: %v", dpType, err)
+	}
+	rootOps, err := c.ovsClient.Where(root).Mutate(root, model.Mutation{
+		Field:   &root.Datapaths,
+		Mutator: ovsdb.MutateOperationInsert,
+		Value:   map[string]string{dpType: dp.UUID},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare Open_vSwitch mutation: %v", err)
+	}
+	return dp, append(dpOps, rootOps...), nil
+}
+
+// conntrackZoneDeleteOps returns the operations releasing the conntrack zones
+// configured for the port.
+func (c *Client) conntrackZoneDeleteOps(ctx context.Context, bridge *ovsModel.Bridge, portName string) ([]ovsdb.Operation, error) {
+	roots := []ovsModel.OpenvSwitch{}
+	if err := c.ovsClient.List(ctx, &roots); err != nil {
+		return nil, fmt.Errorf("failed to list Open_vSwitch: %v", err)
+	}
+	if len(roots) == 0 {
+		return nil, nil
+	}
+	u, ok := roots[0].Datapaths[datapathType(bridge)]
+	if !ok {
+		return nil, nil
+	}
+	dp := &ovsModel.Datapath{UUID: u}
+	if err := c.ovsClient.Get(ctx, dp); err != nil {
+		return nil, fmt.Errorf("failed to find datapath %s: %v", u, err)
+	}
+
+	zones := []int{}
+	for zone, zoneUUID := range dp.CTZones {
+		ctZone := &ovsModel.CTZone{UUID: zoneUUID}
+		if err := c.ovsClient.Get(ctx, ctZone); err != nil {
+			return nil, fmt.Errorf("failed to find conntrack zone %s: %v", zoneUUID, err)
+		}
+		if ctZone.ExternalIDs["iface-id"] == portName {
+			zones = append(zones, zone)
+		}
+	}
+	if len(zones) == 0 {
+		return nil, nil
+	}
+	ops, err := c.ovsClient.Where(dp).Mutate(dp, model.Mutation{
+		Field:   &dp.CTZones,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   zones,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare datapath mutation: %v", err)
+	}
+	return ops, nil
+}
+
+func (c *Client) sameConntrackZone(ctx context.Context, ctZone *ovsModel.CTZone, portName string, limit int, timeouts map[string]int) (bool, error) {
+	if ctZone.ExternalIDs["iface-id"] != portName {
+		return false, nil
+	}
+	currentLimit := 0
+	if ctZone.Limit != nil {
+		currentLimit = *ctZone.Limit
+	}
+	if currentLimit != limit {
+		return false, nil
+	}
+	current := map[string]int{}
+	if ctZone.TimeoutPolicy != nil {
+		policy := &ovsModel.CTTimeoutPolicy{UUID: *ctZone.TimeoutPolicy}
+		if err := c.ovsClient.Get(ctx, policy); err != nil {
+			return false, fmt.Errorf("failed to find conntrack timeout policy %s: %v", *ctZone.TimeoutPolicy, err)
+		}
+		current = policy.Timeouts
+	}
+	if len(current) != len(timeouts) {
+		return false, nil
+	}
+	for k, v := range timeouts {
+		if cv, ok := current[k]; !ok || cv != v {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// datapathType is the datapath of the bridge, "system" unless it uses
+// another one such as the DPDK "netdev".
+func datapathType(bridge *ovsModel.Bridge) string {
+	if bridge.DatapathType == "" {
+		return "system"
+	}
+	return bridge.DatapathType
+}
@@ -0,0 +1,336 @@
+package ovs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	ovsModel "github.com/cybercoder/ik8s-ovn-cni/pkg/ovs/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// flowExportConfigKey records on each exporter row the configuration it was
+// built from, so unchanged exporters are left alone.
+const flowExportConfigKey = "ovn.ik8s.ir/flow-export"
+
+// FlowExport configures the flow exporters of a bridge. A nil exporter is
+// turned off. Targets are "ip:port" collectors. CollectorSets are the IPFIX
+// collectors of the per-flow sampling of OpenFlow sample actions, such as
+// the ones of OVN sampled ACLs, keyed by their collector set ID.
+type FlowExport struct {
+	SFlow         *SFlowExport         `json:"sflow,omitempty"`
+	IPFIX         *IPFIXExport         `json:"ipfix,omitempty"`
+	NetFlow       *NetFlowExport       `json:"netflow,omitempty"`
+	CollectorSets []CollectorSetExport `json:"collectorSets,omitempty"`
+}
+
+type SFlowExport struct {
+	Targets []string `json:"targets"`
+	// Agent is the interface whose address identifies the exporter.
+	Agent string `json:"agent,omitempty"`
+	// Sampling samples one packet in Sampling, Polling is the counter
+	// polling interval in seconds and Header the sampled header size.
+	Sampling int `json:"sampling,omitempty"`
+	Polling  int `json:"polling,omitempty"`
+	Header   int `json:"header,omitempty"`
+}
+
+type IPFIXExport struct {
+	Targets            []string `json:"targets"`
+	Sampling           int      `json:"sampling,omitempty"`
+	CacheActiveTimeout int      `json:"cacheActiveTimeout,omitempty"`
+	CacheMaxFlows      int      `json:"cacheMaxFlows,omitempty"`
+	ObsDomainID        int      `json:"obsDomainId,omitempty"`
+	ObsPointID         int      `json:"obsPointId,omitempty"`
+}
+
+type CollectorSetExport struct {
+	ID                 int      `json:"id"`
+	Targets            []string `json:"targets"`
+	CacheActiveTimeout int      `json:"cacheActiveTimeout,omitempty"`
+	CacheMaxFlows      int      `json:"cacheMaxFlows,omitempty"`
+}
+
+type NetFlowExport struct {
+	Targets          []string `json:"targets"`
+	ActiveTimeout    int      `json:"activeTimeout,omitempty"`
+	EngineID         int      `json:"engineId,omitempty"`
+	EngineType       int      `json:"engineType,omitempty"`
+	AddIDToInterface bool     `json:"addIdToInterface,omitempty"`
+}
+
+// SetBridgeFlowExport points the sFlow, IPFIX and NetFlow exporters of the
+// bridge at the configured collectors. The exporter tables are not root
+// tables, so replaced rows are collected once the bridge drops them.
+func (c *Client) SetBridgeFlowExport(bridgeName string, cfg FlowExport) error {
+	ctx := context.Background()
+
+	bridge := &ovsModel.Bridge{Name: bridgeName}
+	if err := c.ovsClient.Get(ctx, bridge); err != nil {
+		return fmt.Errorf("failed to get bridge %q: %v", bridgeName, err)
+	}
+
+	var ops []ovsdb.Operation
+	fields := []any{}
+
+	sflowConfig := exportConfig(cfg.SFlow)
+	if current, err := c.sflowConfig(ctx, bridge.Sflow); err != nil {
+		return err
+	} else if current != sflowConfig {
+		bridge.Sflow = nil
+		if cfg.SFlow != nil {
+			row := &ovsModel.SFlow{
+				UUID:        uuid.New().String(),
+				Targets:     cfg.SFlow.Targets,
+				Sampling:    optionalInt(cfg.SFlow.Sampling),
+				Polling:     optionalInt(cfg.SFlow.Polling),
+				Header:      optionalInt(cfg.SFlow.Header),
+				ExternalIDs: map[string]string{flowExportConfigKey: sflowConfig},
+			}
+			if cfg.SFlow.Agent != "" {
+				row.Agent = &cfg.SFlow.Agent
+			}
+			createOps, err := c.ovsClient.Create(row)
+			if err != nil {
+				return fmt.Errorf("failed to create sflow: %v", err)
+			}
+			ops = append(ops, createOps...)
+			bridge.Sflow = &row.UUID
+		}
+		fields = append(fields, &bridge.Sflow)
+	}
+
+	ipfixConfig := exportConfig(cfg.IPFIX)
+	if current, err := c.ipfixConfig(ctx, bridge.IPFIX); err != nil {
+		return err
+	} else if current != ipfixConfig {
+		bridge.IPFIX = nil
+		if cfg.IPFIX != nil {
+			row := &ovsModel.IPFIX{
+				UUID:               uuid.New().String(),
+				Targets:            cfg.IPFIX.Targets,
+				Sampling:           optionalInt(cfg.IPFIX.Sampling),
+				CacheActiveTimeout: optionalInt(cfg.IPFIX.CacheActiveTimeout),
+				CacheMaxFlows:      optionalInt(cfg.IPFIX.CacheMaxFlows),
+				ObsDomainID:        optionalInt(cfg.IPFIX.ObsDomainID),
+				ObsPointID:         optionalInt(cfg.IPFIX.ObsPointID),
+				ExternalIDs:        map[string]string{flowExportConfigKey: ipfixConfig},
+			}
+			createOps, err := c.ovsClient.Create(row)
+			if err != nil {
+				return fmt.Errorf("failed to create ipfix: %v", err)
+			}
+			ops = append(ops, createOps...)
+			bridge.IPFIX = &row.UUID
+		}
+		fields = append(fields, &bridge.IPFIX)
+	}
+
+	netflowConfig := exportConfig(cfg.NetFlow)
+	if current, err := c.netflowConfig(ctx, bridge.Netflow); err != nil {
+		return err
+	} else if current != netflowConfig {
+		bridge.Netflow = nil
+		if cfg.NetFlow != nil {
+			row := &ovsModel.NetFlow{
+				UUID:             uuid.New().String(),
+				Targets:          cfg.NetFlow.Targets,
+				ActiveTimeout:    cfg.NetFlow.ActiveTimeout,
+				EngineID:         optionalInt(cfg.NetFlow.EngineID),
+				EngineType:       optionalInt(cfg.NetFlow.EngineType),
+				AddIDToInterface: cfg.NetFlow.AddIDToInterface,
+				ExternalIDs:      map[string]string{flowExportConfigKey: netflowConfig},
+			}
+			createOps, err := c.ovsClient.Create(row)
+			if err != nil {
+				return fmt.Errorf("failed to create netflow: %v", err)
+			}
+			ops = append(ops, createOps...)
+			bridge.Netflow = &row.UUID
+		}
+		fields = append(fields, &bridge.Netflow)
+	}
+
+	collectorOps, err := c.collectorSetOps(ctx, bridge, cfg.CollectorSets)
+	if err != nil {
+		return err
+	}
+	ops = append(ops, collectorOps...)
+
+	if len(fields) == 0 && len(ops) == 0 {
+		return nil
+	}
+	if len(fields) > 0 {
+		bridgeOps, err := c.ovsClient.Where(bridge).Update(bridge, fields...)
+		if err != nil {
+			return fmt.Errorf("failed to prepare bridge update: %v", err)
+		}
+		ops = append(ops, bridgeOps...)
+	}
+	reply, err := c.ovsClient.Transact(ctx, ops...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	log.Printf("✅ Configured flow export on bridge %s", bridgeName)
+	return nil
+}
+
+// collectorSetOps returns the operations replacing the flow sample collector
+// sets of the bridge whose configuration changed and removing the ones no
+// longer configured. Flow_Sample_Collector_Set is a root table, so stale rows
+// are deleted explicitly; their IPFIX rows go with them.
+func (c *Client) collectorSetOps(ctx context.Context, bridge *ovsModel.Bridge, sets []CollectorSetExport) ([]ovsdb.Operation, error) {
+	var existing []ovsModel.FlowSampleCollectorSet
+	err := c.ovsClient.WhereCache(func(s *ovsModel.FlowSampleCollectorSet) bool {
+		_, ok := s.ExternalIDs[flowExportConfigKey]
+		return ok && s.Bridge == bridge.UUID
+	}).List(ctx, &existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list flow sample collector sets: %v", err)
+	}
+	current := map[int]ovsModel.FlowSampleCollectorSet{}
+	for _, s := range existing {
+		current[s.ID] = s
+	}
+
+	var ops []ovsdb.Operation
+	for _, set := range sets {
+		config := exportConfig(&set)
+		if s, ok := current[set.ID]; ok {
+			delete(current, set.ID)
+			if s.ExternalIDs[flowExportConfigKey] == config {
+				continue
+			}
+			deleteOps, err := c.ovsClient.Where(&s).Delete()
+			if err != nil {
+				return nil, fmt.Errorf("failed to prepare flow sample collector set %d delete: %v", s.ID, err)
+			}
+			ops = append(ops, deleteOps...)
+		}
+		ipfix := &ovsModel.IPFIX{
+			UUID:               uuid.New().String(),
+			Targets:            set.Targets,
+			CacheActiveTimeout: optionalInt(set.CacheActiveTimeout),
+			CacheMaxFlows:      optionalInt(set.CacheMaxFlows),
+		}
+		row := &ovsModel.FlowSampleCollectorSet{
+			UUID:        uuid.New().String(),
+			Bridge:      bridge.UUID,
+			ID:          set.ID,
+			IPFIX:       &ipfix.UUID,
+			ExternalIDs: map[string]string{flowExportConfigKey: config},
+		}
+		createOps, err := c.ovsClient.Create(ipfix, row)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create flow sample collector set %d: %v", set.ID, err)
+		}
+		ops = append(ops, createOps...)
+	}
+	for _, s := range current {
+		deleteOps, err := c.ovsClient.Where(&s).Delete()
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare flow sample collector set %d delete: %v", s.ID, err)
+		}
+		ops = append(ops, deleteOps...)
+	}
+	return ops, nil
+}
+
+// MergeInterfaceExternalIDs sets the given external_ids on an interface,
+// keeping its other keys.
+func (c *Client) MergeInterfaceExternalIDs(ifName string, ids map[string]string) error {
+	ctx := context.Background()
+
+	iface := &ovsModel.Interface{Name: ifName}
+	if err := c.ovsClient.Get(ctx, iface); err != nil {
+		return fmt.Errorf("failed to find interface %s: %v", ifName, err)
+	}
+	changed := false
+	for k, v := range ids {
+		if iface.ExternalIDs[k] != v {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	// A map insert mutation does not replace existing keys, so drop them first.
+	keys := make([]string, 0, len(ids))
+	for k := range ids {
+		keys = append(keys, k)
+	}
+	ops, err := c.ovsClient.Where(iface).Mutate(iface,
+		model.Mutation{Field: &iface.ExternalIDs, Mutator: ovsdb.MutateOperationDelete, Value: keys},
+		model.Mutation{Field: &iface.ExternalIDs, Mutator: ovsdb.MutateOperationInsert, Value: ids},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to prepare interface mutation: %v", err)
+	}
+	reply, err := c.ovsClient.Transact(ctx, ops...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	return nil
+}
+
+func (c *Client) sflowConfig(ctx context.Context, u *string) (string, error) {
+	if u == nil {
+		return exportConfig[*SFlowExport](nil), nil
+	}
+	row := &ovsModel.SFlow{UUID: *u}
+	if err := c.ovsClient.Get(ctx, row); err != nil {
+		return "", fmt.Errorf("failed to find sflow %s: %v", *u, err)
+	}
+	return row.ExternalIDs[flowExportConfigKey], nil
+}
+
+func (c *Client) ipfixConfig(ctx context.Context, u *string) (string, error) {
+	if u == nil {
+		return exportConfig[*IPFIXExport](nil), nil
+	}
+	row := &ovsModel.IPFIX{UUID: *u}
+	if err := c.ovsClient.Get(ctx, row); err != nil {
+		return "", fmt.Errorf("failed to find ipfix %s: %v", *u, err)
+	}
+	return row.ExternalIDs[flowExportConfigKey], nil
+}
+
+func (c *Client) netflowConfig(ctx context.Context, u *string) (string, error) {
+	if u == nil {
+		return exportConfig[*NetFlowExport](nil), nil
+	}
+	row := &ovsModel.NetFlow{UUID: *u}
+	if err := c.ovsClient.Get(ctx, row); err != nil {
+		return "", fmt.Errorf("failed to find netflow %s: %v", *u, err)
+	}
+	return row.ExternalIDs[flowExportConfigKey], nil
+}
+
+// exportConfig serializes an exporter configuration, "" when turned off.
+func exportConfig[T any](cfg *T) string {
+	if cfg == nil {
+		return ""
+	}
+	b, _ := json.Marshal(cfg)
+	return string(b)
+}
+
+func optionalInt(v int) *int {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
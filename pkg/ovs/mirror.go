@@ -0,0 +1,141 @@
+package ovs
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	ovsModel "github.com/cybercoder/ik8s-ovn-cni/pkg/ovs/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// AddInternalPort adds an OVS internal port to the bridge for local use,
+// such as the output of a capture mirror. Unlike AddPort it sets no
+// iface-id, so ovn-controller never binds the port to a logical port.
+func (c *Client) AddInternalPort(bridgeName, portName string) error {
+	ctx := context.Background()
+
+	bridge := &ovsModel.Bridge{Name: bridgeName}
+	if err := c.ovsClient.Get(ctx, bridge); err != nil {
+		return fmt.Errorf("failed to get bridge %q: %v", bridgeName, err)
+	}
+	ops, portUUID, err := c.createPortOps(portName, "internal")
+	if err != nil {
+		return err
+	}
+	mutateOps, err := c.ovsClient.Where(bridge).Mutate(bridge, model.Mutation{
+		Field:   &bridge.Ports,
+		Mutator: ovsdb.MutateOperationInsert,
+		Value:   []string{portUUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare mutation: %v", err)
+	}
+	reply, err := c.ovsClient.Transact(ctx, append(ops, mutateOps...)...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	log.Printf("✅ Added internal port %s to bridge %s", portName, bridgeName)
+	return nil
+}
+
+// AddMirror mirrors the traffic sent and received by srcPort to outputPort,
+// both ports of the bridge.
+func (c *Client) AddMirror(bridgeName, mirrorName, srcPort, outputPort string) error {
+	ctx := context.Background()
+
+	bridge := &ovsModel.Bridge{Name: bridgeName}
+	if err := c.ovsClient.Get(ctx, bridge); err != nil {
+		return fmt.Errorf("failed to get bridge %q: %v", bridgeName, err)
+	}
+	src := &ovsModel.Port{Name: srcPort}
+	if err := c.ovsClient.Get(ctx, src); err != nil {
+		return fmt.Errorf("failed to find port %s: %v", srcPort, err)
+	}
+	out := &ovsModel.Port{Name: outputPort}
+	if err := c.ovsClient.Get(ctx, out); err != nil {
+		return fmt.Errorf("failed to find port %s: %v", outputPort, err)
+	}
+
+	// Mirror is not a root table, so create and reference it together.
+	mirror := &ovsModel.Mirror{
+		UUID:          uuid.New().String(),
+		Name:          mirrorName,
+		SelectSrcPort: []string{src.UUID},
+		SelectDstPort: []string{src.UUID},
+		OutputPort:    &out.UUID,
+	}
+	mirrorOps, err := c.ovsClient.Create(mirror)
+	if err != nil {
+		return fmt.Errorf("failed to create mirror: %v", err)
+	}
+	mutateOps, err := c.ovsClient.Where(bridge).Mutate(bridge, model.Mutation{
+		Field:   &bridge.Mirrors,
+		Mutator: ovsdb.MutateOperationInsert,
+		Value:   []string{mirror.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare bridge mutation: %v", err)
+	}
+	reply, err := c.ovsClient.Transact(ctx, append(mirrorOps, mutateOps...)...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	log.Printf("✅ Mirroring port %s to %s on bridge %s", srcPort, outputPort, bridgeName)
+	return nil
+}
+
+// DelMirror removes the mirror from the bridge.
+func (c *Client) DelMirror(bridgeName, mirrorName string) error {
+	ctx := context.Background()
+
+	bridge := &ovsModel.Bridge{Name: bridgeName}
+	if err := c.ovsClient.Get(ctx, bridge); err != nil {
+		return fmt.Errorf("failed to get bridge %q: %v", bridgeName, err)
+	}
+	mirrors := []ovsModel.Mirror{}
+	err := c.ovsClient.WhereCache(func(m *ovsModel.Mirror) bool {
+		return m.Name == mirrorName
+	}).List(ctx, &mirrors)
+	if err != nil {
+		return fmt.Errorf("failed to query mirror cache: %v", err)
+	}
+	if len(mirrors) == 0 {
+		return nil
+	}
+	uuids := []string{}
+	for _, m := range mirrors {
+		uuids = append(uuids, m.UUID)
+	}
+	mutateOps, err := c.ovsClient.Where(bridge).Mutate(bridge, model.Mutation{
+		Field:   &bridge.Mirrors,
+		Mutator: ovsdb.MutateOperationDelete,
+		Value:   uuids,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare bridge mutation: %v", err)
+	}
+	reply, err := c.ovsClient.Transact(ctx, mutateOps...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	log.Printf("🧹 Deleted mirror %s from bridge %s", mirrorName, bridgeName)
+	return nil
+}
@@ -54,7 +54,21 @@ func (c *Client) DelPort(bridgeName, portName string) error {
 		portOp = append(portOp, ifaceOp...)
 	}
 
-	// 5. Run all operations in one transaction
+	// 5. Delete the QoS and queues shaping the port
+	qosOps, err := c.portQoSDeleteOps(ctx, port)
+	if err != nil {
+		return err
+	}
+	portOp = append(portOp, qosOps...)
+
+	// 6. Release the conntrack zones configured for the port
+	zoneOps, err := c.conntrackZoneDeleteOps(ctx, bridge, portName)
+	if err != nil {
+		return err
+	}
+	portOp = append(portOp, zoneOps...)
+
+	// 7. Run all operations in one transaction
 	ops := append(mutateOps, portOp...)
 	reply, err := c.ovsClient.Transact(ctx, ops...)
 	if err != nil {
@@ -84,11 +98,13 @@ func (c *Client) AddPort(bridgeName, portName, ifaceType, hostmac string) error
 		UUID: ifaceUUID.String(),
 		Name: portName,
 		Type: ifaceType, // "system" for veth, "internal" if OVS creates it
-		MAC:  &hostmac,
 		ExternalIDs: map[string]string{
 			"iface-id": portName,
 		},
 	}
+	if hostmac != "" {
+		iface.MAC = &hostmac
+	}
 
 	ifaceOp, err := c.ovsClient.Create(iface)
 	if err != nil {
@@ -147,3 +163,37 @@ func (c *Client) SetInterfaceExternalIDs(ifName string, ids map[string]string) e
 	_, err := c.ovsClient.Transact(context.Background(), []ovsdb.Operation{op}...)
 	return err
 }
+
+// SetInterfaceIngressPolicing limits the traffic OVS receives from an
+// interface, in kbps with a burst in kb. A zero rate removes the limit.
+func (c *Client) SetInterfaceIngressPolicing(ifName string, rateKbps, burstKb int) error {
+	ctx := context.Background()
+
+	iface := &ovsModel.Interface{Name: ifName}
+	if err := c.ovsClient.Get(ctx, iface); err != nil {
+		return fmt.Errorf("failed to find interface %s: %v", ifName, err)
+	}
+	if rateKbps == 0 {
+		burstKb = 0
+	}
+	if iface.IngressPolicingRate == rateKbps && iface.IngressPolicingBurst == burstKb {
+		return nil
+	}
+	iface.IngressPolicingRate = rateKbps
+	iface.IngressPolicingBurst = burstKb
+	ops, err := c.ovsClient.Where(iface).Update(iface, &iface.IngressPolicingRate, &iface.IngressPolicingBurst)
+	if err != nil {
+		return fmt.Errorf("failed to prepare interface update: %v", err)
+	}
+	reply, err := c.ovsClient.Transact(ctx, ops...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	log.Printf("✅ Set ingress policing %d kbps (burst %d kb) on interface %s", rateKbps, burstKb, ifName)
+	return nil
+}
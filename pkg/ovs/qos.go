@@ -0,0 +1,185 @@
+package ovs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	ovsModel "github.com/cybercoder/ik8s-ovn-cni/pkg/ovs/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// SetPortQueue shapes the traffic OVS sends out of a port with a linux-htb
+// QoS whose default queue guarantees minRate and caps maxRate, in bits per
+// second. Priority orders the queue against the others of the port, lower
+// first. All zero removes the QoS.
+func (c *Client) SetPortQueue(portName string, minRate, maxRate, priority int) error {
+	ctx := context.Background()
+
+	port := &ovsModel.Port{Name: portName}
+	if err := c.ovsClient.Get(ctx, port); err != nil {
+		return fmt.Errorf("failed to find port %s: %v", portName, err)
+	}
+
+	var ops []ovsdb.Operation
+	var err error
+	if minRate == 0 && maxRate == 0 && priority == 0 {
+		if port.QOS == nil {
+			return nil
+		}
+		qosOps, err := c.portQoSDeleteOps(ctx, port)
+		if err != nil {
+			return err
+		}
+		port.QOS = nil
+		ops, err = c.ovsClient.Where(port).Update(port, &port.QOS)
+		if err != nil {
+			return fmt.Errorf("failed to prepare port update: %v", err)
+		}
+		ops = append(ops, qosOps...)
+	} else {
+		qosConfig := map[string]string{}
+		if maxRate > 0 {
+			qosConfig["max-rate"] = strconv.Itoa(maxRate)
+		}
+		queueConfig := map[string]string{}
+		for key, v := range map[string]int{"min-rate": minRate, "max-rate": maxRate, "priority": priority} {
+			if v > 0 {
+				queueConfig[key] = strconv.Itoa(v)
+			}
+		}
+		ids := map[string]string{"iface-id": portName}
+
+		if port.QOS == nil {
+			queue := &ovsModel.Queue{UUID: uuid.New().String(), OtherConfig: queueConfig, ExternalIDs: ids}
+			qos := &ovsModel.QoS{
+				UUID:        uuid.New().String(),
+				Type:        "linux-htb",
+				OtherConfig: qosConfig,
+				Queues:      map[int]string{0: queue.UUID},
+				ExternalIDs: ids,
+			}
+			queueOps, err := c.ovsClient.Create(queue)
+			if err != nil {
+				return fmt.Errorf("failed to create queue: %v", err)
+			}
+			qosOps, err := c.ovsClient.Create(qos)
+			if err != nil {
+				return fmt.Errorf("failed to create qos: %v", err)
+			}
+			port.QOS = &qos.UUID
+			portOps, err := c.ovsClient.Where(port).Update(port, &port.QOS)
+			if err != nil {
+				return fmt.Errorf("failed to prepare port update: %v", err)
+			}
+			ops = append(queueOps, append(qosOps, portOps...)...)
+		} else {
+			ops, err = c.portQueueUpdateOps(ctx, *port.QOS, qosConfig, queueConfig, ids)
+			if err != nil {
+				return err
+			}
+			if len(ops) == 0 {
+				return nil
+			}
+		}
+	}
+
+	reply, err := c.ovsClient.Transact(ctx, ops...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	log.Printf("✅ Set queue min-rate=%d max-rate=%d priority=%d on port %s", minRate, maxRate, priority, portName)
+	return nil
+}
+
+// portQueueUpdateOps updates the QoS of a port and its default queue in place,
+// creating the queue when the QoS has lost it.
+func (c *Client) portQueueUpdateOps(ctx context.Context, qosUUID string, qosConfig, queueConfig, ids map[string]string) ([]ovsdb.Operation, error) {
+	qos := &ovsModel.QoS{UUID: qosUUID}
+	if err := c.ovsClient.Get(ctx, qos); err != nil {
+		return nil, fmt.Errorf("failed to find qos %s: %v", qosUUID, err)
+	}
+
+	var ops []ovsdb.Operation
+	queueUUID, ok := qos.Queues[0]
+	if !ok {
+		queue := &ovsModel.Queue{UUID: uuid.New().String(), OtherConfig: queueConfig, ExternalIDs: ids}
+		queueOps, err := c.ovsClient.Create(queue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create queue: %v", err)
+		}
+		ops = append(ops, queueOps...)
+		queueUUID = queue.UUID
+	} else {
+		queue := &ovsModel.Queue{UUID: queueUUID}
+		if err := c.ovsClient.Get(ctx, queue); err != nil {
+			return nil, fmt.Errorf("failed to find queue %s: %v", queueUUID, err)
+		}
+		if !mapsEqual(queue.OtherConfig, queueConfig) {
+			queue.OtherConfig = queueConfig
+			queueOps, err := c.ovsClient.Where(queue).Update(queue, &queue.OtherConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to prepare queue update: %v", err)
+			}
+			ops = append(ops, queueOps...)
+		}
+	}
+
+	if !ok || !mapsEqual(qos.OtherConfig, qosConfig) || qos.Type != "linux-htb" {
+		if qos.Queues == nil {
+			qos.Queues = map[int]string{}
+		}
+		qos.Queues[0] = queueUUID
+		qos.Type = "linux-htb"
+		qos.OtherConfig = qosConfig
+		qosOps, err := c.ovsClient.Where(qos).Update(qos, &qos.Type, &qos.OtherConfig, &qos.Queues)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare qos update: %v", err)
+		}
+		ops = append(ops, qosOps...)
+	}
+	return ops, nil
+}
+
+// portQoSDeleteOps deletes the QoS of a port and its queues. QoS and Queue
+// are root tables, so they outlive the port unless deleted explicitly.
+func (c *Client) portQoSDeleteOps(ctx context.Context, port *ovsModel.Port) ([]ovsdb.Operation, error) {
+	if port.QOS == nil {
+		return nil, nil
+	}
+	qos := &ovsModel.QoS{UUID: *port.QOS}
+	if err := c.ovsClient.Get(ctx, qos); err != nil {
+		return nil, fmt.Errorf("failed to find qos %s: %v", *port.QOS, err)
+	}
+	ops, err := c.ovsClient.Where(qos).Delete()
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare qos delete: %v", err)
+	}
+	for _, queueUUID := range qos.Queues {
+		queueOps, err := c.ovsClient.Where(&ovsModel.Queue{UUID: queueUUID}).Delete()
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare queue delete: %v", err)
+		}
+		ops = append(ops, queueOps...)
+	}
+	return ops, nil
+}
+
+func mapsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package ovs
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	ovsModel "github.com/cybercoder/ik8s-ovn-cni/pkg/ovs/models"
+	"github.com/google/uuid"
+	"github.com/ovn-kubernetes/libovsdb/model"
+	"github.com/ovn-kubernetes/libovsdb/ovsdb"
+)
+
+// AddVhostUserPort plugs a VM into the bridge through a DPDK vhost-user
+// socket. OVS is the client: QEMU in the virt-launcher pod creates the
+// socket at socketPath and OVS connects to it. The bridge must use the
+// netdev datapath.
+func (c *Client) AddVhostUserPort(bridgeName, portName, socketPath string) error {
+	ctx := context.Background()
+
+	bridge := &ovsModel.Bridge{Name: bridgeName}
+	if err := c.ovsClient.Get(ctx, bridge); err != nil {
+		return fmt.Errorf("failed to get bridge %q: %v", bridgeName, err)
+	}
+	if bridge.DatapathType != "netdev" {
+		return fmt.Errorf("bridge %s uses the %q datapath, vhost-user needs netdev", bridgeName, datapathType(bridge))
+	}
+
+	iface := &ovsModel.Interface{
+		UUID:    uuid.New().String(),
+		Name:    portName,
+		Type:    "dpdkvhostuserclient",
+		Options: map[string]string{"vhost-server-path": socketPath},
+		ExternalIDs: map[string]string{
+			"iface-id": portName,
+		},
+	}
+	ifaceOps, err := c.ovsClient.Create(iface)
+	if err != nil {
+		return fmt.Errorf("failed to create interface: %v", err)
+	}
+	port := &ovsModel.Port{
+		UUID:       uuid.New().String(),
+		Name:       portName,
+		Interfaces: []string{iface.UUID},
+	}
+	portOps, err := c.ovsClient.Create(port)
+	if err != nil {
+		return fmt.Errorf("failed to create port: %v", err)
+	}
+	mutateOps, err := c.ovsClient.Where(bridge).Mutate(bridge, model.Mutation{
+		Field:   &bridge.Ports,
+		Mutator: ovsdb.MutateOperationInsert,
+		Value:   []string{port.UUID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare mutation: %v", err)
+	}
+
+	ops := append(ifaceOps, append(portOps, mutateOps...)...)
+	reply, err := c.ovsClient.Transact(ctx, ops...)
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	for i, r := range reply {
+		if r.Error != "" {
+			log.Printf("OVSDB error: %d %s (%s)", i, r.Error, r.Details)
+		}
+	}
+	// A bad socket path, a duplicate port or a datapath without vhost-user
+	// support only show up in the reply, and leave the VM without a port.
+	if _, err := ovsdb.CheckOperationResults(reply, ops); err != nil {
+		return fmt.Errorf("failed to add vhost-user port %s: %v", portName, err)
+	}
+	log.Printf("✅ Added vhost-user port %s to bridge %s (socket %s)", portName, bridgeName, socketPath)
+	return nil
+}
+
+// IsVhostUserPort reports whether the interface of the port is a vhost-user
+// one, which the linux-htb QoS of the kernel datapath cannot shape.
+func (c *Client) IsVhostUserPort(portName string) (bool, error) {
+	iface := &ovsModel.Interface{Name: portName}
+	if err := c.ovsClient.Get(context.Background(), iface); err != nil {
+		return false, fmt.Errorf("failed to find interface %s: %v", portName, err)
+	}
+	return iface.Type == "dpdkvhostuserclient", nil
+}